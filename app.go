@@ -2,14 +2,23 @@ package net
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/segmentio/ksuid"
 	"github.com/weiqiangxu/common-config/logger"
+	"github.com/weiqiangxu/net/registry"
+	"github.com/weiqiangxu/net/transport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -18,25 +27,48 @@ type AppInfo interface {
 	ID() string
 	Name() string
 	Version() string
+	Metadata() map[string]string
+	Endpoint() []string
+	Registry() *prometheus.Registry
+	TracerProvider() trace.TracerProvider
+	Propagator() propagation.TextMapPropagator
 }
 
 // App is an application components lifecycle manager
 type App struct {
-	opts   options
-	ctx    context.Context
-	cancel func()
+	opts     options
+	ctx      context.Context
+	cancel   func()
+	mu       sync.Mutex
+	instance *registry.ServiceInstance
+	stopOnce sync.Once
+	stopped  bool
+
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
 }
 
 // New create an application lifecycle manager.
 func New(opts ...Option) *App {
 	options := options{
-		ctx:  context.Background(),
-		sigs: []os.Signal{syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT},
+		ctx:         context.Background(),
+		sigs:        []os.Signal{syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT},
+		stopTimeout: 10 * time.Second,
+
+		registrarTimeout: 10 * time.Second,
+		parentBased:      true,
 	}
 	options.id = ksuid.New().String()
 	for _, o := range opts {
 		o(&options)
 	}
+	if options.registry == nil {
+		options.registry = prometheus.NewRegistry()
+		options.registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
 	ctx, cancel := context.WithCancel(options.ctx)
 	return &App{
 		ctx:    ctx,
@@ -54,35 +86,110 @@ func (a *App) Name() string { return a.opts.name }
 // Version returns app version.
 func (a *App) Version() string { return a.opts.version }
 
+// Metadata returns service metadata.
+func (a *App) Metadata() map[string]string { return a.opts.metadata }
+
+// Registry returns the registry the metrics of the app are registered on.
+func (a *App) Registry() *prometheus.Registry { return a.opts.registry }
+
+// TracerProvider returns the tracer provider of the app, nil until it runs
+// with an exporter.
+func (a *App) TracerProvider() trace.TracerProvider {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.tracerProvider
+}
+
+// Propagator returns the propagator of the app, nil until it runs with an
+// exporter.
+func (a *App) Propagator() propagation.TextMapPropagator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.propagator
+}
+
+// Endpoint returns endpoints of the registered instance.
+func (a *App) Endpoint() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.instance != nil {
+		return a.instance.Endpoints
+	}
+	return nil
+}
+
 // Run executes all OnStart hooks registered with the application's Lifecycle.
 func (a *App) Run() error {
-	ctx := NewContext(a.ctx, a)
-	if a.opts.agentAddr != "" {
-		tp, err := configAgent(ctx, a.opts.agentAddr, a.opts.name, a.opts.version, a.opts.attributes...)
+	sctx := NewContext(a.ctx, a)
+	if len(a.opts.exporters) > 0 {
+		propagator, err := a.opts.propagator()
+		if err != nil {
+			return err
+		}
+		tp, err := configAgent(sctx, a.opts)
 		if err != nil {
 			return err
 		}
+		a.mu.Lock()
+		a.tracerProvider = tp
+		a.propagator = propagator
+		a.mu.Unlock()
+		if a.opts.global {
+			otel.SetErrorHandler(&configAgentErrorHandler{})
+			otel.SetTracerProvider(tp)
+			otel.SetTextMapPropagator(propagator)
+		}
 		defer func() {
 			if err := tp.Shutdown(context.Background()); err != nil {
 				logger.Errorf("Shutting down tracer provider (%v)", err)
 			}
 		}()
 	}
-	eg, ctx := errgroup.WithContext(ctx)
-	wg := sync.WaitGroup{}
-	for _, srv := range a.opts.servers {
-		srv := srv
-		eg.Go(func() error {
-			<-ctx.Done() // wait for stop signal
-			return srv.Stop(ctx)
-		})
-		wg.Add(1)
-		eg.Go(func() error {
-			wg.Done()
-			return srv.Start(ctx)
-		})
-	}
-	wg.Wait()
+	for _, fn := range a.opts.beforeStart {
+		if err := fn(sctx); err != nil {
+			// every after stop hook runs, they release whatever the
+			// previous before start hooks acquired and skip the rest
+			if e := a.afterStop(); e != nil {
+				logger.Errorf("[app] after stop hook err=%v", e)
+			}
+			return err
+		}
+	}
+	eg, ctx := errgroup.WithContext(sctx)
+	// started holds the indexes in Server of the servers to stop
+	started := make([]int, 0, len(a.opts.servers))
+	for i, srv := range a.opts.servers {
+		if ctx.Err() != nil {
+			break
+		}
+		if a.startServer(ctx, eg, srv) {
+			started = append(started, i)
+		}
+	}
+	eg.Go(func() error {
+		<-ctx.Done() // wait for stop signal or a failed server
+		// a failed server does not go through Stop, the instance is still
+		// deregistered before the servers stop
+		if err := a.Stop(); err != nil {
+			logger.Errorf("[app] stop err=%v", err)
+		}
+		return a.stopServers(started)
+	})
+	if ctx.Err() == nil {
+		if err := a.afterStart(sctx); err != nil {
+			// shut down the started servers and unwind the start hooks
+			if e := a.Stop(); e != nil {
+				logger.Errorf("[app] before stop hook err=%v", e)
+			}
+			if e := eg.Wait(); e != nil && !errors.Is(e, context.Canceled) {
+				logger.Errorf("[app] stop servers err=%v", e)
+			}
+			if e := a.afterStop(); e != nil {
+				logger.Errorf("[app] after stop hook err=%v", e)
+			}
+			return err
+		}
+	}
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, a.opts.sigs...)
 	eg.Go(func() error {
@@ -96,19 +203,198 @@ func (a *App) Run() error {
 		}
 	})
 	if err := eg.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+		if e := a.afterStop(); e != nil {
+			logger.Errorf("[app] after stop hook err=%v", e)
+		}
 		return err
 	}
-	return nil
+	return a.afterStop()
+}
+
+// Stop gracefully stops the application, it runs the before stop hooks and
+// deregisters the instance once, the later calls return nil.
+func (a *App) Stop() (err error) {
+	a.stopOnce.Do(func() {
+		err = a.stop()
+	})
+	return err
 }
 
-// Stop gracefully stops the application.
-func (a *App) Stop() error {
+func (a *App) stop() (err error) {
+	sctx := NewContext(a.opts.ctx, a)
+	for _, fn := range a.opts.beforeStop {
+		if e := fn(sctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	a.mu.Lock()
+	instance := a.instance
+	a.instance = nil
+	a.stopped = true
+	a.mu.Unlock()
+	if a.opts.registrar != nil && instance != nil {
+		ctx, cancel := context.WithTimeout(sctx, a.opts.registrarTimeout)
+		defer cancel()
+		if e := a.opts.registrar.Deregister(ctx, instance); e != nil && err == nil {
+			err = e
+		}
+	}
 	if a.cancel != nil {
 		a.cancel()
 	}
+	return err
+}
+
+// afterStart registers the service instance and runs every after start hook.
+func (a *App) afterStart(ctx context.Context) error {
+	if a.opts.registrar != nil {
+		instance, err := a.buildInstance()
+		if err != nil {
+			return err
+		}
+		rctx, rcancel := context.WithTimeout(ctx, a.opts.registrarTimeout)
+		defer rcancel()
+		if err := a.opts.registrar.Register(rctx, instance); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		stopped := a.stopped
+		if !stopped {
+			a.instance = instance
+		}
+		a.mu.Unlock()
+		if stopped {
+			// a server failed while registering, Stop missed the instance
+			dctx, dcancel := context.WithTimeout(NewContext(a.opts.ctx, a), a.opts.registrarTimeout)
+			defer dcancel()
+			return a.opts.registrar.Deregister(dctx, instance)
+		}
+	}
+	for _, fn := range a.opts.afterStart {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// buildInstance describes the app for the registrar, the endpoints of the
+// servers are used unless they are given with the Endpoint option.
+func (a *App) buildInstance() (*registry.ServiceInstance, error) {
+	endpoints := make([]string, 0, len(a.opts.endpoints))
+	for _, e := range a.opts.endpoints {
+		endpoints = append(endpoints, e.String())
+	}
+	if len(endpoints) == 0 {
+		for _, srv := range a.opts.servers {
+			if r, ok := srv.(transport.Endpointer); ok {
+				e, err := r.Endpoint()
+				if err != nil {
+					return nil, err
+				}
+				endpoints = append(endpoints, e.String())
+			}
+		}
+	}
+	return &registry.ServiceInstance{
+		ID:        a.opts.id,
+		Name:      a.opts.name,
+		Version:   a.opts.version,
+		Metadata:  a.opts.metadata,
+		Endpoints: endpoints,
+	}, nil
+}
+
+// startServer runs srv in eg and, when srv is a transport.Readier, waits
+// until it is listening. It reports false if srv exited without becoming
+// ready, such a server has nothing to stop.
+func (a *App) startServer(ctx context.Context, eg *errgroup.Group, srv transport.Server) bool {
+	done := make(chan struct{})
+	eg.Go(func() error {
+		defer close(done)
+		return srv.Start(ctx)
+	})
+	r, ok := srv.(transport.Readier)
+	if !ok {
+		return true
+	}
+	select {
+	case <-r.Ready():
+		return true
+	case <-done:
+		select {
+		case <-r.Ready():
+			return true
+		default:
+			return false
+		}
+	case <-ctx.Done():
+		// still starting, stop it along with the others
+		return true
+	}
+}
+
+// stopServers stops the servers at indexes in the reverse order of their
+// start, so that a server never outlives the servers it depends on.
+func (a *App) stopServers(indexes []int) (err error) {
+	for i := len(indexes) - 1; i >= 0; i-- {
+		if e := a.stopServer(indexes[i]); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// stopServer stops the i-th server with a fresh context bounded by the stop
+// timeout, the run context is already cancelled at this point.
+func (a *App) stopServer(i int) error {
+	srv := a.opts.servers[i]
+	ctx := NewContext(a.opts.ctx, a)
+	if a.opts.stopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.opts.stopTimeout)
+		defer cancel()
+	}
+	err := srv.Stop(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Errorf("[app] server %s did not drain in %s and was closed forcibly", serverName(i, srv), a.opts.stopTimeout)
+	}
+	return err
+}
+
+// serverName names the i-th server in the logs by its type and index in
+// Server, and by its endpoint when it listens.
+func serverName(i int, srv transport.Server) string {
+	name := fmt.Sprintf("#%d %T", i, srv)
+	if r, ok := srv.(transport.Readier); ok {
+		select {
+		case <-r.Ready():
+		default:
+			// Endpoint would start listening
+			return name
+		}
+	}
+	if e, ok := srv.(transport.Endpointer); ok {
+		if u, err := e.Endpoint(); err == nil {
+			name += " " + u.String()
+		}
+	}
+	return name
+}
+
+// afterStop runs every after stop hook and returns the first error, the
+// hooks get a context which is not cancelled yet so that they can still
+// flush to remote services.
+func (a *App) afterStop() (err error) {
+	sctx := NewContext(a.opts.ctx, a)
+	for _, fn := range a.opts.afterStop {
+		if e := fn(sctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
 type appKey struct{}
 
 // NewContext returns a new Context that carries value.
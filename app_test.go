@@ -2,7 +2,16 @@ package net
 
 import (
 	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weiqiangxu/net/registry"
+	"github.com/weiqiangxu/net/transport"
 )
 
 func TestNew(t *testing.T) {
@@ -39,10 +48,432 @@ func TestNew(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			got := New(tt.args.opts...)
 			t.Logf("%#v", got)
-			t.Logf("a.opts.agentAddr =%#v", got.opts.agentAddr)
+			t.Logf("a.opts.exporters =%d", len(got.opts.exporters))
 			t.Logf("trace id=%#v", TraceID(got.ctx))
 			t.Logf("span id=%#v", SpanID(got.ctx))
 			t.Logf("span =%#v", Span(got.ctx))
 		})
 	}
 }
+
+func TestApp_Hooks(t *testing.T) {
+	errHook := errors.New("hook failed")
+	tests := []struct {
+		name    string
+		fail    string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "run every hook in order",
+			want: []string{"beforeStart", "afterStart", "beforeStop", "afterStop"},
+		},
+		{
+			name:    "before start failed unwind with after stop",
+			fail:    "beforeStart",
+			want:    []string{"beforeStart", "afterStop"},
+			wantErr: errHook,
+		},
+		{
+			name:    "after start failed unwind with stop hooks",
+			fail:    "afterStart",
+			want:    []string{"beforeStart", "afterStart", "beforeStop", "afterStop"},
+			wantErr: errHook,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			var app *App
+			hook := func(name string) func(context.Context) error {
+				return func(ctx context.Context) error {
+					if _, ok := FromContext(ctx); !ok {
+						t.Errorf("%s hook without app info", name)
+					}
+					got = append(got, name)
+					if name == tt.fail {
+						return errHook
+					}
+					if name == "afterStart" {
+						return app.Stop()
+					}
+					return nil
+				}
+			}
+			app = New(
+				Name("hooks"),
+				BeforeStart(hook("beforeStart")),
+				AfterStart(hook("afterStart")),
+				BeforeStop(hook("beforeStop")),
+				AfterStop(hook("afterStop")),
+			)
+			if err := app.Run(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hooks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApp_PartialStart(t *testing.T) {
+	errHook := errors.New("hook failed")
+	var (
+		db    *sync.Mutex
+		got   []string
+		opens []string
+	)
+	app := New(
+		Name("partial"),
+		BeforeStart(func(ctx context.Context) error {
+			opens = append(opens, "cache")
+			return nil
+		}),
+		BeforeStart(func(ctx context.Context) error {
+			return errHook
+		}),
+		BeforeStart(func(ctx context.Context) error {
+			db = &sync.Mutex{}
+			opens = append(opens, "db")
+			return nil
+		}),
+		AfterStop(func(ctx context.Context) error {
+			got = append(got, "close cache")
+			return nil
+		}),
+		AfterStop(func(ctx context.Context) error {
+			// the db was never opened
+			if db == nil {
+				got = append(got, "skip db")
+				return nil
+			}
+			got = append(got, "close db")
+			return nil
+		}),
+	)
+	if err := app.Run(); !errors.Is(err, errHook) {
+		t.Fatalf("Run() error = %v, want %v", err, errHook)
+	}
+	if want := []string{"cache"}; !reflect.DeepEqual(opens, want) {
+		t.Errorf("opened = %v, want %v", opens, want)
+	}
+	// every after stop hook runs
+	if want := []string{"close cache", "skip db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after stop hooks = %v, want %v", got, want)
+	}
+}
+
+// mockServer is a transport.Server which blocks until it is stopped.
+type mockServer struct {
+	stopped chan struct{}
+	// drain is how long Stop takes to finish the in-flight requests.
+	drain   time.Duration
+	stopCtx context.Context
+	stopErr error
+}
+
+func newMockServer(drain time.Duration) *mockServer {
+	return &mockServer{stopped: make(chan struct{}), drain: drain}
+}
+
+func (s *mockServer) Start(ctx context.Context) error {
+	<-s.stopped
+	return nil
+}
+
+func (s *mockServer) Stop(ctx context.Context) error {
+	defer close(s.stopped)
+	s.stopCtx = ctx
+	select {
+	case <-time.After(s.drain):
+	case <-ctx.Done():
+		s.stopErr = ctx.Err()
+	}
+	return s.stopErr
+}
+
+func TestApp_StopTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		drain   time.Duration
+		timeout time.Duration
+		wantErr error
+	}{
+		{
+			name:    "server drained in time",
+			drain:   10 * time.Millisecond,
+			timeout: time.Second,
+		},
+		{
+			name:    "server force closed after timeout",
+			drain:   time.Minute,
+			timeout: 50 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newMockServer(tt.drain)
+			var app *App
+			app = New(
+				Server(srv),
+				StopTimeout(tt.timeout),
+				AfterStart(func(ctx context.Context) error {
+					return app.Stop()
+				}),
+			)
+			start := time.Now()
+			if err := app.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > tt.timeout+time.Second {
+				t.Errorf("Run() took %s, want less than %s", elapsed, tt.timeout)
+			}
+			if _, ok := srv.stopCtx.Deadline(); !ok {
+				t.Error("stop context without deadline")
+			}
+			if !errors.Is(srv.stopErr, tt.wantErr) {
+				t.Errorf("Stop() error = %v, wantErr %v", srv.stopErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+// readyServer is a transport.Readier which records its start and stop.
+type readyServer struct {
+	*mockServer
+	name  string
+	ready chan struct{}
+	mu    *sync.Mutex
+	log   *[]string
+}
+
+func (s *readyServer) record(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.log = append(*s.log, event+" "+s.name)
+}
+
+func (s *readyServer) Start(ctx context.Context) error {
+	s.record("start")
+	// bind lazily to prove the app waits for it
+	time.Sleep(20 * time.Millisecond)
+	s.record("ready")
+	close(s.ready)
+	return s.mockServer.Start(ctx)
+}
+
+func (s *readyServer) Stop(ctx context.Context) error {
+	s.record("stop")
+	return s.mockServer.Stop(ctx)
+}
+
+func (s *readyServer) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func TestApp_ServerOrder(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	newServer := func(name string) *readyServer {
+		return &readyServer{mockServer: newMockServer(0), name: name, ready: make(chan struct{}), mu: &mu, log: &got}
+	}
+	grpcSrv, httpSrv := newServer("grpc"), newServer("http")
+	var app *App
+	app = New(
+		Server(grpcSrv, httpSrv),
+		AfterStart(func(ctx context.Context) error {
+			select {
+			case <-httpSrv.Ready():
+			default:
+				t.Error("after start hooks run before the servers are ready")
+			}
+			return app.Stop()
+		}),
+	)
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start grpc", "ready grpc", "start http", "ready http", "stop http", "stop grpc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+// endpointServer is a transport.Endpointer, it listens once ready is closed.
+type endpointServer struct {
+	*mockServer
+	ready    chan struct{}
+	endpoint *url.URL
+}
+
+func (s *endpointServer) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *endpointServer) Endpoint() (*url.URL, error) {
+	return s.endpoint, nil
+}
+
+func TestServerName(t *testing.T) {
+	listening := &endpointServer{mockServer: newMockServer(0), ready: make(chan struct{}), endpoint: &url.URL{Scheme: "http", Host: "127.0.0.1:8080"}}
+	close(listening.ready)
+	starting := &endpointServer{mockServer: newMockServer(0), ready: make(chan struct{}), endpoint: listening.endpoint}
+	tests := []struct {
+		name string
+		i    int
+		srv  transport.Server
+		want string
+	}{
+		{name: "no endpoint", i: 0, srv: newMockServer(0), want: "#0 *net.mockServer"},
+		{name: "listening", i: 1, srv: listening, want: "#1 *net.endpointServer http://127.0.0.1:8080"},
+		{name: "not ready", i: 2, srv: starting, want: "#2 *net.endpointServer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serverName(tt.i, tt.srv); got != tt.want {
+				t.Errorf("serverName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApp_Registrar(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+	endpoint := &url.URL{Scheme: "grpc", Host: "127.0.0.1:9000"}
+	var app *App
+	app = New(
+		ID("1"),
+		Name("user"),
+		Endpoint(endpoint),
+		Registrar(r),
+		AfterStart(func(ctx context.Context) error {
+			services, err := r.GetService(ctx, "user")
+			if err != nil {
+				return err
+			}
+			if len(services) != 1 || services[0].Endpoints[0] != endpoint.String() {
+				t.Errorf("registered services = %+v", services)
+			}
+			if info, _ := FromContext(ctx); !reflect.DeepEqual(info.Endpoint(), []string{endpoint.String()}) {
+				t.Errorf("Endpoint() = %v", info.Endpoint())
+			}
+			return app.Stop()
+		}),
+	)
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if services, _ := r.GetService(context.Background(), "user"); len(services) != 0 {
+		t.Errorf("services after stop = %+v, want none", services)
+	}
+}
+
+// failingServer is a transport.Server which fails once fail is closed.
+type failingServer struct {
+	fail chan struct{}
+}
+
+func (s *failingServer) Start(ctx context.Context) error {
+	select {
+	case <-s.fail:
+		return errors.New("crash")
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (s *failingServer) Stop(ctx context.Context) error {
+	return nil
+}
+
+func TestApp_ServerFailure(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+	srv := &failingServer{fail: make(chan struct{})}
+	var got []string
+	app := New(
+		Name("user"),
+		Endpoint(&url.URL{Scheme: "grpc", Host: "127.0.0.1:9000"}),
+		Registrar(r),
+		Server(srv),
+		AfterStart(func(ctx context.Context) error {
+			close(srv.fail)
+			return nil
+		}),
+		BeforeStop(func(ctx context.Context) error {
+			got = append(got, "beforeStop")
+			return nil
+		}),
+		AfterStop(func(ctx context.Context) error {
+			got = append(got, "afterStop")
+			return nil
+		}),
+	)
+	if err := app.Run(); err == nil || err.Error() != "crash" {
+		t.Fatalf("Run() error = %v, want crash", err)
+	}
+	if services, _ := r.GetService(context.Background(), "user"); len(services) != 0 {
+		t.Errorf("services after the failure = %+v, want none", services)
+	}
+	// Stop is a no-op once the app stopped
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"beforeStop", "afterStop"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hooks = %v, want %v", got, want)
+	}
+}
+
+func TestApp_Registry(t *testing.T) {
+	custom := prometheus.NewRegistry()
+	tests := []struct {
+		name string
+		opts []Option
+		want func(*prometheus.Registry) bool
+	}{
+		{
+			name: "default registry with the go collector",
+			want: func(reg *prometheus.Registry) bool {
+				families, err := reg.Gather()
+				if err != nil {
+					return false
+				}
+				for _, f := range families {
+					if f.GetName() == "go_goroutines" {
+						return true
+					}
+				}
+				return false
+			},
+		},
+		{
+			name: "registry option",
+			opts: []Option{Registry(custom)},
+			want: func(reg *prometheus.Registry) bool { return reg == custom },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *prometheus.Registry
+			app := New(append(tt.opts, BeforeStart(func(ctx context.Context) error {
+				if info, ok := FromContext(ctx); ok {
+					got = info.Registry()
+				}
+				return errors.New("stop")
+			}))...)
+			_ = app.Run()
+			if got != app.Registry() {
+				t.Errorf("FromContext registry = %p, want the app registry %p", got, app.Registry())
+			}
+			if !tt.want(got) {
+				t.Errorf("unexpected registry %p", got)
+			}
+		})
+	}
+	if New().Registry() == New().Registry() {
+		t.Error("apps share their default registry")
+	}
+}
@@ -0,0 +1,101 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/weiqiangxu/common-config/logger"
+)
+
+// LogWriter writes the lines of ContextLogger.
+type LogWriter interface {
+	Infow(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// commonLogger is the LogWriter of the common-config logger.
+type commonLogger struct{}
+
+func (commonLogger) Infow(msg string, keysAndValues ...interface{}) {
+	logger.Infow(msg, keysAndValues...)
+}
+
+func (commonLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	logger.Errorw(msg, keysAndValues...)
+}
+
+var (
+	writerMu sync.RWMutex
+	writer   LogWriter = commonLogger{}
+)
+
+// SetLogWriter writes the lines of every ContextLogger with w instead of the
+// common-config logger, a nil w restores it.
+func SetLogWriter(w LogWriter) {
+	if w == nil {
+		w = commonLogger{}
+	}
+	writerMu.Lock()
+	defer writerMu.Unlock()
+	writer = w
+}
+
+func logWriter() LogWriter {
+	writerMu.RLock()
+	defer writerMu.RUnlock()
+	return writer
+}
+
+// ContextLogger logs with the trace_id and span_id of a context as fields,
+// so that the log lines link to the traces.
+type ContextLogger struct {
+	fields []interface{}
+}
+
+// Logger returns a ContextLogger for the span in ctx, the lines carry no
+// trace fields when there is none.
+func Logger(ctx context.Context) ContextLogger {
+	return ContextLogger{fields: traceFields(ctx)}
+}
+
+// traceFields returns the trace_id and span_id fields of the span in ctx.
+func traceFields(ctx context.Context) []interface{} {
+	traceID := TraceID(ctx)
+	if traceID == "" {
+		return nil
+	}
+	return []interface{}{"trace_id", traceID, "span_id", SpanID(ctx)}
+}
+
+// with appends the trace fields to a copy of keysAndValues.
+func (l ContextLogger) with(keysAndValues []interface{}) []interface{} {
+	if len(l.fields) == 0 {
+		return keysAndValues
+	}
+	return append(append(make([]interface{}, 0, len(keysAndValues)+len(l.fields)), keysAndValues...), l.fields...)
+}
+
+func (l ContextLogger) Info(args ...interface{}) {
+	logWriter().Infow(fmt.Sprint(args...), l.fields...)
+}
+
+func (l ContextLogger) Infof(format string, args ...interface{}) {
+	logWriter().Infow(fmt.Sprintf(format, args...), l.fields...)
+}
+
+func (l ContextLogger) Infow(msg string, keysAndValues ...interface{}) {
+	logWriter().Infow(msg, l.with(keysAndValues)...)
+}
+
+func (l ContextLogger) Error(args ...interface{}) {
+	logWriter().Errorw(fmt.Sprint(args...), l.fields...)
+}
+
+func (l ContextLogger) Errorf(format string, args ...interface{}) {
+	logWriter().Errorw(fmt.Sprintf(format, args...), l.fields...)
+}
+
+func (l ContextLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	logWriter().Errorw(msg, l.with(keysAndValues)...)
+}
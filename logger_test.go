@@ -0,0 +1,89 @@
+package net
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/weiqiangxu/net/tool/logtest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLogger(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex(w3cTraceID)
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []interface{}
+	}{
+		{
+			name: "no span",
+			ctx:  context.Background(),
+			want: []interface{}{"status", 200},
+		},
+		{
+			name: "span",
+			ctx:  traced,
+			want: []interface{}{"status", 200, "trace_id", w3cTraceID, "span_id", "00f067aa0ba902b7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv := make([]interface{}, 2, 8)
+			kv[0], kv[1] = "status", 200
+			got := Logger(tt.ctx).with(kv)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+			// the spare capacity of the caller is not written
+			if extra := kv[:cap(kv)][2]; extra != nil {
+				t.Errorf("caller slice written with %v", extra)
+			}
+		})
+	}
+}
+
+func TestLogger_Writer(t *testing.T) {
+	rec := &logtest.Recorder{}
+	SetLogWriter(rec)
+	defer SetLogWriter(nil)
+	traceID, _ := trace.TraceIDFromHex(w3cTraceID)
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	l := Logger(trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	})))
+	tests := []struct {
+		name      string
+		log       func()
+		wantMsg   string
+		wantLevel string
+	}{
+		{name: "info", log: func() { l.Info("info ", 1) }, wantMsg: "info 1", wantLevel: "info"},
+		{name: "infof", log: func() { l.Infof("infof %d", 2) }, wantMsg: "infof 2", wantLevel: "info"},
+		{name: "infow", log: func() { l.Infow("infow", "status", 200) }, wantMsg: "infow", wantLevel: "info"},
+		{name: "error", log: func() { l.Error("error ", 3) }, wantMsg: "error 3", wantLevel: "error"},
+		{name: "errorf", log: func() { l.Errorf("errorf %d", 4) }, wantMsg: "errorf 4", wantLevel: "error"},
+		{name: "errorw", log: func() { l.Errorw("errorw", "status", 500) }, wantMsg: "errorw", wantLevel: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.log()
+			line, ok := rec.Wait(tt.wantMsg, 0)
+			if !ok {
+				t.Fatalf("no line logged with %q", tt.wantMsg)
+			}
+			if line.Level != tt.wantLevel {
+				t.Errorf("level = %s, want %s", line.Level, tt.wantLevel)
+			}
+			if line.Fields["trace_id"] != w3cTraceID || line.Fields["span_id"] != "00f067aa0ba902b7" {
+				t.Errorf("fields = %v, want the trace fields", line.Fields)
+			}
+		})
+	}
+}
@@ -2,9 +2,14 @@ package net
 
 import (
 	"context"
+	"net/url"
 	"os"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weiqiangxu/net/registry"
 	"github.com/weiqiangxu/net/transport"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Option is an application option.
@@ -14,14 +19,33 @@ type TracerFn func(o *options) error
 
 // options is an application options.
 type options struct {
-	id         string
-	name       string
-	version    string
-	ctx        context.Context
-	sigs       []os.Signal
-	servers    []transport.Server
-	attributes []KeyValue
-	agentAddr  string
+	id          string
+	name        string
+	version     string
+	metadata    map[string]string
+	endpoints   []*url.URL
+	ctx         context.Context
+	sigs        []os.Signal
+	servers     []transport.Server
+	attributes  []KeyValue
+	exporters   []traceExporter
+	propagators []string
+	global      bool
+	registry    *prometheus.Registry
+
+	rootSampler sdkTrace.Sampler
+	parentBased bool
+	sampleRules []SampleRule
+
+	stopTimeout time.Duration
+
+	registrar        registry.Registrar
+	registrarTimeout time.Duration
+
+	beforeStart []func(context.Context) error
+	beforeStop  []func(context.Context) error
+	afterStart  []func(context.Context) error
+	afterStop   []func(context.Context) error
 }
 
 // ID with service id.
@@ -39,12 +63,23 @@ func Version(version string) Option {
 	return func(o *options) { o.version = version }
 }
 
+// Metadata with service metadata.
+func Metadata(md map[string]string) Option {
+	return func(o *options) { o.metadata = md }
+}
+
+// Endpoint with service endpoint, it overrides the endpoints of the servers.
+func Endpoint(endpoints ...*url.URL) Option {
+	return func(o *options) { o.endpoints = endpoints }
+}
+
 // Context with service context.
 func Context(ctx context.Context) Option {
 	return func(o *options) { o.ctx = ctx }
 }
 
-// Server with transport servers.
+// Server with transport servers, they are started in the given order and
+// stopped in the reverse one.
 func Server(srv ...transport.Server) Option {
 	return func(o *options) { o.servers = srv }
 }
@@ -54,10 +89,126 @@ func Signal(sigs ...os.Signal) Option {
 	return func(o *options) { o.sigs = sigs }
 }
 
-// Tracing with agent address and span attributes
+// StopTimeout with the maximum duration every server gets to drain, once it
+// is exceeded the server is closed forcibly.
+func StopTimeout(t time.Duration) Option {
+	return func(o *options) { o.stopTimeout = t }
+}
+
+// Registrar with service registry, the instance is registered once every
+// server is listening and deregistered before the servers stop.
+func Registrar(r registry.Registrar) Option {
+	return func(o *options) { o.registrar = r }
+}
+
+// RegistrarTimeout with registrar timeout.
+func RegistrarTimeout(t time.Duration) Option {
+	return func(o *options) { o.registrarTimeout = t }
+}
+
+// Registry with the prometheus registry of the app, the servers and clients
+// of the transports register their metrics on it. It defaults to a new
+// registry with the go and process collectors.
+func Registry(r *prometheus.Registry) Option {
+	return func(o *options) { o.registry = r }
+}
+
+// Tracing with agent address and span attributes, the spans are exported
+// with OTLP over gRPC in plain text to the agent, see ExportOTLP. No spans
+// are exported to an empty address.
 func Tracing(agentAddr string, attributes ...KeyValue) Option {
 	return func(o *options) {
-		o.agentAddr = agentAddr
-		o.attributes = attributes
+		o.attributes = append(o.attributes, attributes...)
+		if agentAddr != "" {
+			ExportOTLP(agentAddr)(o)
+		}
+	}
+}
+
+// TraceAttributes with the attributes of the spans besides the service name
+// and version.
+func TraceAttributes(attributes ...KeyValue) Option {
+	return func(o *options) { o.attributes = append(o.attributes, attributes...) }
+}
+
+// Propagators with the names of the propagators which inject the trace
+// context into the outgoing requests, e.g. PropagatorTraceContext. The
+// incoming requests are extracted with any known propagator. Default
+// DefaultPropagators.
+func Propagators(names ...string) Option {
+	return func(o *options) { o.propagators = names }
+}
+
+// GlobalTracing installs the tracer provider and the propagator of the app
+// as the otel globals when it runs, for the libraries which only use those,
+// along with an error handler logging the errors of the exporters.
+// The app and its servers and clients do not need it.
+func GlobalTracing(global bool) Option {
+	return func(o *options) { o.global = global }
+}
+
+// Sampler with the sampler of the traces started by the app, default
+// sdkTrace.TraceIDRatioBased(1). The health checks and metrics scrapes are
+// never sampled.
+func Sampler(s sdkTrace.Sampler) Option {
+	return func(o *options) { o.rootSampler = s }
+}
+
+// SampleRatio samples the given fraction of the traces.
+func SampleRatio(ratio float64) Option {
+	return Sampler(sdkTrace.TraceIDRatioBased(ratio))
+}
+
+// SampleNever samples no trace, the spans are still propagated.
+func SampleNever() Option {
+	return Sampler(sdkTrace.NeverSample())
+}
+
+// SampleRateLimit samples at most perSecond spans every second.
+func SampleRateLimit(perSecond float64) Option {
+	return Sampler(RateLimitSampler(perSecond))
+}
+
+// SampleParentBased with whether the spans with a parent follow its
+// decision instead of the sampler, default true.
+func SampleParentBased(parentBased bool) Option {
+	return func(o *options) { o.parentBased = parentBased }
+}
+
+// SampleRules with rules deciding the spans of some routes or methods, they
+// take precedence over the sampler and the parent.
+func SampleRules(rules ...SampleRule) Option {
+	return func(o *options) { o.sampleRules = append(o.sampleRules, rules...) }
+}
+
+// BeforeStart run funcs before app starts. When one of them fails the later
+// ones do not run, yet every AfterStop hook does, see AfterStop.
+func BeforeStart(fn func(context.Context) error) Option {
+	return func(o *options) {
+		o.beforeStart = append(o.beforeStart, fn)
+	}
+}
+
+// BeforeStop run funcs before app stops
+func BeforeStop(fn func(context.Context) error) Option {
+	return func(o *options) {
+		o.beforeStop = append(o.beforeStop, fn)
+	}
+}
+
+// AfterStart run funcs after app starts
+func AfterStart(fn func(context.Context) error) Option {
+	return func(o *options) {
+		o.afterStart = append(o.afterStart, fn)
+	}
+}
+
+// AfterStop run funcs after app stops. They all run once the app stopped or
+// failed to start, even if a BeforeStart hook failed before the resource they
+// release was acquired, so they must tolerate a partial start, e.g. skip a
+// nil pool.
+func AfterStop(fn func(context.Context) error) Option {
+	return func(o *options) {
+		o.afterStop = append(o.afterStop, fn)
 	}
 }
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	_ Registrar = (*MemoryRegistry)(nil)
+	_ Discovery = (*MemoryRegistry)(nil)
+)
+
+// MemoryRegistry is an in process registry, it is useful for tests and for
+// single binaries which do not run a discovery system.
+type MemoryRegistry struct {
+	mu       sync.RWMutex
+	services map[string][]*ServiceInstance
+	watchers map[string]map[*memoryWatcher]struct{}
+}
+
+// NewMemoryRegistry create an empty in process registry.
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		services: make(map[string][]*ServiceInstance),
+		watchers: make(map[string]map[*memoryWatcher]struct{}),
+	}
+}
+
+// Register add service or replace the instance with the same id.
+func (r *MemoryRegistry) Register(ctx context.Context, service *ServiceInstance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	instances := r.services[service.Name]
+	for i, ins := range instances {
+		if ins.ID == service.ID {
+			instances[i] = service
+			r.notify(service.Name)
+			return nil
+		}
+	}
+	r.services[service.Name] = append(instances, service)
+	r.notify(service.Name)
+	return nil
+}
+
+// Deregister remove the instance with the same id as service.
+func (r *MemoryRegistry) Deregister(ctx context.Context, service *ServiceInstance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	instances := r.services[service.Name]
+	for i, ins := range instances {
+		if ins.ID == service.ID {
+			r.services[service.Name] = append(instances[:i:i], instances[i+1:]...)
+			r.notify(service.Name)
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetService return a copy of the instances registered with serviceName.
+func (r *MemoryRegistry) GetService(ctx context.Context, serviceName string) ([]*ServiceInstance, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	instances := make([]*ServiceInstance, len(r.services[serviceName]))
+	copy(instances, r.services[serviceName])
+	return instances, nil
+}
+
+// Watch the instances of serviceName, the first Next returns the current ones.
+func (r *MemoryRegistry) Watch(ctx context.Context, serviceName string) (Watcher, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &memoryWatcher{
+		ctx:      ctx,
+		cancel:   cancel,
+		event:    make(chan struct{}, 1),
+		name:     serviceName,
+		registry: r,
+	}
+	w.event <- struct{}{}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watchers[serviceName] == nil {
+		r.watchers[serviceName] = make(map[*memoryWatcher]struct{})
+	}
+	r.watchers[serviceName][w] = struct{}{}
+	return w, nil
+}
+
+// notify wakes up the watchers of name, r.mu must be held.
+func (r *MemoryRegistry) notify(name string) {
+	for w := range r.watchers[name] {
+		select {
+		case w.event <- struct{}{}:
+		default:
+			// an event is pending already
+		}
+	}
+}
+
+type memoryWatcher struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	event    chan struct{}
+	name     string
+	registry *MemoryRegistry
+}
+
+func (w *memoryWatcher) Next() ([]*ServiceInstance, error) {
+	select {
+	case <-w.ctx.Done():
+		return nil, w.ctx.Err()
+	case <-w.event:
+		return w.registry.GetService(w.ctx, w.name)
+	}
+}
+
+func (w *memoryWatcher) Stop() error {
+	w.cancel()
+	w.registry.mu.Lock()
+	defer w.registry.mu.Unlock()
+	delete(w.registry.watchers[w.name], w)
+	return nil
+}
@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRegistry(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRegistry()
+	w, err := r.Watch(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := w.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+	if got, err := w.Next(); err != nil || len(got) != 0 {
+		t.Fatalf("first Next() = %v, %v, want no instances", got, err)
+	}
+	instance := &ServiceInstance{ID: "1", Name: "user", Endpoints: []string{"grpc://127.0.0.1:9000"}}
+	tests := []struct {
+		name string
+		fn   func() error
+		want int
+	}{
+		{
+			name: "register",
+			fn:   func() error { return r.Register(ctx, instance) },
+			want: 1,
+		},
+		{
+			name: "register the same id again",
+			fn:   func() error { return r.Register(ctx, instance) },
+			want: 1,
+		},
+		{
+			name: "register another id",
+			fn:   func() error { return r.Register(ctx, &ServiceInstance{ID: "2", Name: "user"}) },
+			want: 2,
+		},
+		{
+			name: "deregister",
+			fn:   func() error { return r.Deregister(ctx, instance) },
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := w.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Next() = %d instances, want %d", len(got), tt.want)
+			}
+			services, _ := r.GetService(ctx, "user")
+			if len(services) != tt.want {
+				t.Errorf("GetService() = %d instances, want %d", len(services), tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRegistry_WatchStop(t *testing.T) {
+	r := NewMemoryRegistry()
+	w, err := r.Watch(context.Background(), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Next(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = w.Stop()
+	}()
+	if _, err := w.Next(); err != context.Canceled {
+		t.Errorf("Next() after Stop() error = %v, want %v", err, context.Canceled)
+	}
+}
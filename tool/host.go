@@ -3,9 +3,13 @@ package tool
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"strconv"
 )
 
+// SchemeOfUnix is the endpoint scheme of unix domain socket listeners.
+const SchemeOfUnix = "unix"
+
 // isValidIP check addr is ip
 func isValidIP(addr string) bool {
 	ip := net.ParseIP(addr)
@@ -77,3 +81,16 @@ func Extract(hostPort string, lis net.Listener) (string, error) {
 	}
 	return "", nil
 }
+
+// Endpoint returns the endpoint url of lis with scheme, unix domain sockets
+// have no host port so the socket path is used instead.
+func Endpoint(scheme, hostPort string, lis net.Listener) (*url.URL, error) {
+	if addr, ok := lis.Addr().(*net.UnixAddr); ok {
+		return &url.URL{Scheme: SchemeOfUnix, Path: addr.Name}, nil
+	}
+	addr, err := Extract(hostPort, lis)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: scheme, Host: addr}, nil
+}
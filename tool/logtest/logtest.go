@@ -0,0 +1,62 @@
+// Package logtest records the log lines of net.ContextLogger so that the
+// tests can assert their fields.
+package logtest
+
+import (
+	"sync"
+	"time"
+)
+
+// Line is a recorded log line.
+type Line struct {
+	Level  string
+	Msg    string
+	Fields map[string]interface{}
+}
+
+// Recorder is a net.LogWriter which keeps every line, install it with
+// net.SetLogWriter.
+type Recorder struct {
+	mu    sync.Mutex
+	lines []Line
+}
+
+func (r *Recorder) Infow(msg string, keysAndValues ...interface{}) {
+	r.record("info", msg, keysAndValues)
+}
+
+func (r *Recorder) Errorw(msg string, keysAndValues ...interface{}) {
+	r.record("error", msg, keysAndValues)
+}
+
+func (r *Recorder) record(level, msg string, keysAndValues []interface{}) {
+	fields := make(map[string]interface{}, len(keysAndValues)/2)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); ok {
+			fields[key] = keysAndValues[i+1]
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, Line{Level: level, Msg: msg, Fields: fields})
+}
+
+// Wait returns the first line logged with msg, it waits up to timeout for
+// the lines logged once the response is sent.
+func (r *Recorder) Wait(msg string, timeout time.Duration) (Line, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		r.mu.Lock()
+		for _, l := range r.lines {
+			if l.Msg == msg {
+				r.mu.Unlock()
+				return l, true
+			}
+		}
+		r.mu.Unlock()
+		if time.Now().After(deadline) {
+			return Line{}, false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
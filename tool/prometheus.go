@@ -0,0 +1,20 @@
+package tool
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register registers c on reg, or returns the equal collector registered
+// before, so that the servers and clients of an app share their metrics.
+func Register(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+	are := prometheus.AlreadyRegisteredError{}
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,45 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	first := prometheus.NewCounter(prometheus.CounterOpts{Name: "requests_total", Help: "Requests."})
+	tests := []struct {
+		name    string
+		c       prometheus.Collector
+		want    prometheus.Collector
+		wantErr bool
+	}{
+		{
+			name: "new collector",
+			c:    first,
+			want: first,
+		},
+		{
+			name: "equal collector returns the registered one",
+			c:    prometheus.NewCounter(prometheus.CounterOpts{Name: "requests_total", Help: "Requests."}),
+			want: first,
+		},
+		{
+			name:    "conflicting collector",
+			c:       prometheus.NewGauge(prometheus.GaugeOpts{Name: "requests_total", Help: "Other help."}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Register(reg, tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Register() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package tool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/weiqiangxu/common-config/logger"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for changes.
+const DefaultReloadInterval = 10 * time.Second
+
+// TLSConfig describes the certificates of a server or a client. The key pair
+// and the CA come either from files or from PEM bytes, a key pair read from
+// files is reloaded when the files change so renewals need no restart.
+type TLSConfig struct {
+	// CertFile and KeyFile is the key pair on disk.
+	CertFile string
+	KeyFile  string
+	// CertPEM and KeyPEM is the key pair in memory.
+	CertPEM []byte
+	KeyPEM  []byte
+	// SNI is the additional key pairs of a server, the one matching the
+	// server name of the client is used and the key pair above otherwise.
+	SNI []TLSKeyPair
+	// CAFile or CAPEM verifies the peer, the client certificates on a
+	// server and the server certificate on a client. The system roots are
+	// used by clients when it is empty.
+	CAFile string
+	CAPEM  []byte
+	// ClientAuth requires and verifies the client certificates (mTLS).
+	ClientAuth bool
+	// ServerName overrides the name the client verifies.
+	ServerName string
+	// MinVersion with tls.VersionTLS12 when it is zero.
+	MinVersion uint16
+	// CipherSuites limits the cipher suites of TLS 1.2 and below, the ones
+	// of TLS 1.3 are not configurable.
+	CipherSuites []uint16
+	// ReloadInterval with DefaultReloadInterval when it is zero.
+	ReloadInterval time.Duration
+}
+
+// TLSKeyPair is a certificate and its key, on disk or in memory.
+type TLSKeyPair struct {
+	CertFile string
+	KeyFile  string
+	CertPEM  []byte
+	KeyPEM   []byte
+}
+
+// Server returns the tls config of a server.
+func (c TLSConfig) Server() (*tls.Config, error) {
+	conf := c.base()
+	var certs []func() *tls.Certificate
+	pairs := append([]TLSKeyPair{{CertFile: c.CertFile, KeyFile: c.KeyFile, CertPEM: c.CertPEM, KeyPEM: c.KeyPEM}}, c.SNI...)
+	for _, pair := range pairs {
+		cert, err := pair.load(c.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil {
+			certs = append(certs, cert)
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("tls: a server needs a certificate")
+	}
+	conf.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		for _, cert := range certs {
+			if crt := cert(); hello.SupportsCertificate(crt) == nil {
+				return crt, nil
+			}
+		}
+		return certs[0](), nil
+	}
+	pool, err := c.certPool()
+	if err != nil {
+		return nil, err
+	}
+	if c.ClientAuth {
+		if pool == nil {
+			return nil, errors.New("tls: client auth needs a CA")
+		}
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+		conf.ClientCAs = pool
+	}
+	return conf, nil
+}
+
+// Client returns the tls config of a client.
+func (c TLSConfig) Client() (*tls.Config, error) {
+	conf := c.base()
+	conf.ServerName = c.ServerName
+	pair := TLSKeyPair{CertFile: c.CertFile, KeyFile: c.KeyFile, CertPEM: c.CertPEM, KeyPEM: c.KeyPEM}
+	cert, err := pair.load(c.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		conf.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert(), nil
+		}
+	}
+	pool, err := c.certPool()
+	if err != nil {
+		return nil, err
+	}
+	conf.RootCAs = pool
+	return conf, nil
+}
+
+// base returns the version and cipher policy shared by servers and clients.
+func (c TLSConfig) base() *tls.Config {
+	conf := &tls.Config{MinVersion: c.MinVersion, CipherSuites: c.CipherSuites}
+	if conf.MinVersion == 0 {
+		conf.MinVersion = tls.VersionTLS12
+	}
+	return conf
+}
+
+// load returns a func which gives the current certificate, or nil when the
+// key pair is empty.
+func (p TLSKeyPair) load(interval time.Duration) (func() *tls.Certificate, error) {
+	switch {
+	case p.CertFile != "" || p.KeyFile != "":
+		r, err := NewCertReloader(p.CertFile, p.KeyFile, interval)
+		if err != nil {
+			return nil, err
+		}
+		return r.certificate, nil
+	case len(p.CertPEM) > 0 || len(p.KeyPEM) > 0:
+		cert, err := tls.X509KeyPair(p.CertPEM, p.KeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+		return func() *tls.Certificate { return &cert }, nil
+	}
+	return nil, nil
+}
+
+// certPool returns nil when there is no CA.
+func (c TLSConfig) certPool() (*x509.CertPool, error) {
+	caPEM := c.CAPEM
+	if c.CAFile != "" {
+		b, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		caPEM = b
+	}
+	if len(caPEM) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("tls: no certificate found in CA")
+	}
+	return pool, nil
+}
+
+// CertReloader serves a key pair from files and loads it again once the
+// files change, a pair which fails to load keeps the previous one in use.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	checked  time.Time
+}
+
+// NewCertReloader create a reloader which checks the files every interval.
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	modTime, err := r.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate fits tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+// GetClientCertificate fits tls.Config.GetClientCertificate.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate(), nil
+}
+
+func (r *CertReloader) certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < r.interval {
+		return r.cert
+	}
+	r.checked = time.Now()
+	modTime, err := r.lastModified()
+	if err != nil {
+		logger.Errorf("[tls] stat %s catch err=%v", r.certFile, err)
+		return r.cert
+	}
+	if modTime.After(r.modTime) {
+		if err := r.load(modTime); err != nil {
+			logger.Errorf("[tls] reload %s catch err=%v", r.certFile, err)
+		} else {
+			logger.Infof("[tls] reloaded certificate %s", r.certFile)
+		}
+	}
+	return r.cert
+}
+
+// load reads the key pair, r.mu must be held once r is shared.
+func (r *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "tls: load key pair")
+	}
+	// the leaf is parsed once instead of on every handshake
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return errors.Wrap(err, "tls: parse certificate")
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	return nil
+}
+
+// lastModified returns the latest modification time of the key pair files.
+func (r *CertReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,112 @@
+package tool
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weiqiangxu/net/tool/tlstest"
+)
+
+func TestTLSConfig(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	certPEM, keyPEM := ca.Issue(t, "127.0.0.1")
+	tests := []struct {
+		name    string
+		conf    TLSConfig
+		server  bool
+		wantErr bool
+	}{
+		{
+			name:   "server from pem",
+			conf:   TLSConfig{CertPEM: certPEM, KeyPEM: keyPEM},
+			server: true,
+		},
+		{
+			name:    "server without certificate",
+			conf:    TLSConfig{CAPEM: ca.CertPEM},
+			server:  true,
+			wantErr: true,
+		},
+		{
+			name:    "client auth without CA",
+			conf:    TLSConfig{CertPEM: certPEM, KeyPEM: keyPEM, ClientAuth: true},
+			server:  true,
+			wantErr: true,
+		},
+		{
+			name:    "invalid CA",
+			conf:    TLSConfig{CAPEM: []byte("not a certificate")},
+			wantErr: true,
+		},
+		{
+			name: "client with CA",
+			conf: TLSConfig{CAPEM: ca.CertPEM},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := tt.conf.Client
+			if tt.server {
+				build = tt.conf.Server
+			}
+			if _, err := build(); (err != nil) != tt.wantErr {
+				t.Errorf("build tls config error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	write := func(host string) {
+		certPEM, keyPEM := ca.Issue(t, host)
+		if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commonName := func(r *CertReloader) string {
+		cert, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	write("old.local")
+	r, err := NewCertReloader(certFile, keyFile, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := commonName(r); got != "old.local" {
+		t.Fatalf("certificate = %s, want old.local", got)
+	}
+	// a broken renewal keeps the previous certificate
+	future := time.Now().Add(time.Minute)
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE"}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(certFile, future, future)
+	time.Sleep(5 * time.Millisecond)
+	if got := commonName(r); got != "old.local" {
+		t.Fatalf("certificate after broken renewal = %s, want old.local", got)
+	}
+	write("new.local")
+	future = future.Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	time.Sleep(5 * time.Millisecond)
+	if got := commonName(r); got != "new.local" {
+		t.Fatalf("certificate after renewal = %s, want new.local", got)
+	}
+}
@@ -6,17 +6,45 @@ import (
 	"time"
 
 	"github.com/weiqiangxu/common-config/logger"
-	"go.opentelemetry.io/contrib/propagators/b3"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
 	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var Tracer trace.Tracer
+// TracerName is the instrumentation name of the tracers returned by Tracer.
+const TracerName = "application"
+
+// Tracer returns the tracer of the app found in ctx, or of the global tracer
+// provider outside of an app.
+func Tracer(ctx context.Context) trace.Tracer {
+	return TracerProvider(ctx).Tracer(TracerName)
+}
+
+// TracerProvider returns the tracer provider of the app found in ctx, or the
+// global one outside of an app.
+func TracerProvider(ctx context.Context) trace.TracerProvider {
+	if info, ok := FromContext(ctx); ok {
+		if tp := info.TracerProvider(); tp != nil {
+			return tp
+		}
+	}
+	return otel.GetTracerProvider()
+}
+
+// Propagator returns the propagator of the app found in ctx, or the global
+// one outside of an app.
+func Propagator(ctx context.Context) propagation.TextMapPropagator {
+	if info, ok := FromContext(ctx); ok {
+		if p := info.Propagator(); p != nil {
+			return p
+		}
+	}
+	return otel.GetTextMapPropagator()
+}
 
 // KeyValue holds a key and value pair.
 type KeyValue struct {
@@ -30,29 +58,21 @@ func (e *configAgentErrorHandler) Handle(err error) {
 	logger.Errorf("[tracing] %v", err)
 }
 
-func configAgent(ctx context.Context, agentAddr, service, version string, attributes ...KeyValue) (*sdkTrace.TracerProvider, error) {
-	// error handler
-	otel.SetErrorHandler(&configAgentErrorHandler{})
-	expOptions := []otlptracegrpc.Option{
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithEndpoint(agentAddr),
-	}
+// configAgent creates the registered exporters and a tracer provider
+// exporting to all of them, the otel globals are left untouched.
+func configAgent(ctx context.Context, o options) (*sdkTrace.TracerProvider, error) {
 	// connect timeout into root context
 	grpcConnectionTimeout := 3 * time.Second
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithTimeout(ctx, grpcConnectionTimeout)
 	defer cancel()
-	traceExp, err := otlptracegrpc.New(ctx, expOptions...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create the collector trace exporter (%s)", err)
-	}
 	// set up default attribute app name && version
 	attrs := []attribute.KeyValue{
-		semConv.ServiceNameKey.String(service),
-		semConv.ServiceVersionKey.String(version),
+		semConv.ServiceNameKey.String(o.name),
+		semConv.ServiceVersionKey.String(o.version),
 	}
 	// set up additional attributes
-	for _, attr := range attributes {
+	for _, attr := range o.attributes {
 		if attr.Key != "" && attr.Value != "" {
 			attrs = append(attrs, attribute.String(attr.Key, attr.Value))
 		}
@@ -63,19 +83,29 @@ func configAgent(ctx context.Context, agentAddr, service, version string, attrib
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource (%s)", err)
 	}
-	// configured TracerProvider into package variable tp
-	tp := sdkTrace.NewTracerProvider(
-		sdkTrace.WithSampler(sdkTrace.ParentBased(sdkTrace.TraceIDRatioBased(1.0))),
-		sdkTrace.WithBatcher(traceExp,
-			sdkTrace.WithBatchTimeout(5*time.Second),
-			sdkTrace.WithMaxExportBatchSize(10)),
+	tpOptions := []sdkTrace.TracerProviderOption{
+		sdkTrace.WithSampler(o.sampler()),
 		sdkTrace.WithResource(res),
-	)
-	otel.SetTracerProvider(tp)
-	propagator := b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader))
-	otel.SetTextMapPropagator(propagator)
-	Tracer = tp.Tracer("application")
-	return tp, nil
+	}
+	exporters := make([]sdkTrace.SpanExporter, 0, len(o.exporters))
+	for _, e := range o.exporters {
+		exp, err := e.new(ctx)
+		if err != nil {
+			for _, created := range exporters {
+				_ = created.Shutdown(ctx)
+			}
+			return nil, err
+		}
+		exporters = append(exporters, exp)
+		if e.sync {
+			tpOptions = append(tpOptions, sdkTrace.WithSyncer(exp))
+			continue
+		}
+		tpOptions = append(tpOptions, sdkTrace.WithBatcher(exp,
+			sdkTrace.WithBatchTimeout(5*time.Second),
+			sdkTrace.WithMaxExportBatchSize(10)))
+	}
+	return sdkTrace.NewTracerProvider(tpOptions...), nil
 }
 
 func TraceID(ctx context.Context) string {
@@ -0,0 +1,179 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/credentials"
+)
+
+// TraceExporter creates a span exporter when the app runs, the exporter is
+// shut down with the tracer provider when the app stops.
+type TraceExporter func(ctx context.Context) (sdkTrace.SpanExporter, error)
+
+// traceExporter is an exporter of the registry, the synchronous ones export
+// every span as it ends instead of in batches.
+type traceExporter struct {
+	new  TraceExporter
+	sync bool
+}
+
+// OTLPOption is an option of the OTLP exporters.
+type OTLPOption func(o *otlpOptions)
+
+type otlpOptions struct {
+	tlsConf *tls.Config
+	headers map[string]string
+}
+
+// OTLPTLSConfig export over TLS with conf instead of in plain text, see
+// tool.TLSConfig to load it from files or PEM bytes.
+func OTLPTLSConfig(conf *tls.Config) OTLPOption {
+	return func(o *otlpOptions) { o.tlsConf = conf }
+}
+
+// OTLPHeaders with headers sent along the spans, e.g. the authorization of
+// a hosted collector.
+func OTLPHeaders(headers map[string]string) OTLPOption {
+	return func(o *otlpOptions) { o.headers = headers }
+}
+
+// Export registers exporter, the spans are exported in batches.
+func Export(exporter TraceExporter) Option {
+	return func(o *options) {
+		o.exporters = append(o.exporters, traceExporter{new: exporter})
+	}
+}
+
+// ExportOTLP exports the spans with OTLP over gRPC to endpoint, e.g.
+// localhost:4317.
+func ExportOTLP(endpoint string, opts ...OTLPOption) Option {
+	conf := otlpOptions{}
+	for _, o := range opts {
+		o(&conf)
+	}
+	return Export(func(ctx context.Context) (sdkTrace.SpanExporter, error) {
+		expOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+		if conf.tlsConf != nil {
+			expOptions = append(expOptions, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(conf.tlsConf)))
+		} else {
+			expOptions = append(expOptions, otlptracegrpc.WithInsecure())
+		}
+		if len(conf.headers) > 0 {
+			expOptions = append(expOptions, otlptracegrpc.WithHeaders(conf.headers))
+		}
+		exp, err := otlptracegrpc.New(ctx, expOptions...)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the otlp grpc trace exporter")
+		}
+		return exp, nil
+	})
+}
+
+// ExportOTLPHTTP exports the spans with OTLP over HTTP to endpoint, e.g.
+// localhost:4318.
+func ExportOTLPHTTP(endpoint string, opts ...OTLPOption) Option {
+	conf := otlpOptions{}
+	for _, o := range opts {
+		o(&conf)
+	}
+	return Export(func(ctx context.Context) (sdkTrace.SpanExporter, error) {
+		expOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+		if conf.tlsConf != nil {
+			expOptions = append(expOptions, otlptracehttp.WithTLSClientConfig(conf.tlsConf))
+		} else {
+			expOptions = append(expOptions, otlptracehttp.WithInsecure())
+		}
+		if len(conf.headers) > 0 {
+			expOptions = append(expOptions, otlptracehttp.WithHeaders(conf.headers))
+		}
+		exp, err := otlptracehttp.New(ctx, expOptions...)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the otlp http trace exporter")
+		}
+		return exp, nil
+	})
+}
+
+// ExportZipkin exports the spans as Zipkin JSON to the collector url, e.g.
+// http://localhost:9411/api/v2/spans.
+func ExportZipkin(url string) Option {
+	return Export(func(ctx context.Context) (sdkTrace.SpanExporter, error) {
+		exp, err := zipkin.New(url)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the zipkin trace exporter")
+		}
+		return exp, nil
+	})
+}
+
+// ExportStdout pretty prints every span to the standard output as it ends,
+// for the local development.
+func ExportStdout() Option {
+	return func(o *options) {
+		o.exporters = append(o.exporters, traceExporter{sync: true, new: func(ctx context.Context) (sdkTrace.SpanExporter, error) {
+			return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		}})
+	}
+}
+
+// ExportFile writes the spans as JSON lines to path. Once the file grows
+// over maxSize bytes it is rotated to path.1, path.1 to path.2 and so on,
+// keeping maxBackups files at most.
+func ExportFile(path string, maxSize int64, maxBackups int) Option {
+	return Export(func(ctx context.Context) (sdkTrace.SpanExporter, error) {
+		w, err := newRotatingFile(path, maxSize, maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			_ = w.Close()
+			return nil, errors.Wrap(err, "failed to create the file trace exporter")
+		}
+		return &closingExporter{SpanExporter: exp, closer: w}, nil
+	})
+}
+
+// ExportMemory records every span in exp as it ends, the tests read them with
+// exp.GetSpans(), after Run returned as well.
+func ExportMemory(exp *tracetest.InMemoryExporter) Option {
+	return func(o *options) {
+		o.exporters = append(o.exporters, traceExporter{sync: true, new: func(ctx context.Context) (sdkTrace.SpanExporter, error) {
+			return keepingExporter{exp}, nil
+		}})
+	}
+}
+
+// keepingExporter keeps the recorded spans on shutdown, the shutdown of
+// tracetest.InMemoryExporter resets them.
+type keepingExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (e keepingExporter) Shutdown(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// closingExporter closes the file of the exporter on shutdown.
+type closingExporter struct {
+	sdkTrace.SpanExporter
+	closer io.Closer
+}
+
+func (e *closingExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if e := e.closer.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// runTraced runs an app which ends spans named work in its first after start
+// hook and then stops, the batches are flushed once Run returns.
+func runTraced(t *testing.T, spans int, opts ...Option) {
+	var app *App
+	work := AfterStart(func(ctx context.Context) error {
+		for i := 0; i < spans; i++ {
+			_, span := Tracer(ctx).Start(ctx, "work")
+			span.End()
+		}
+		return app.Stop()
+	})
+	app = New(append([]Option{Name("traced"), work}, opts...)...)
+	if err := app.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportMemory(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	runTraced(t, 1, ExportMemory(exp))
+	var got []string
+	for _, s := range exp.GetSpans() {
+		got = append(got, s.Name)
+	}
+	if len(got) != 1 || got[0] != "work" {
+		t.Errorf("recorded spans = %v, want [work]", got)
+	}
+}
+
+func TestExportFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	// every span is over 100 bytes, each file holds a single one
+	runTraced(t, 3, ExportFile(path, 100, 1))
+	for _, name := range []string{path, path + ".1"} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := 0
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			span := struct{ Name string }{}
+			if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+				t.Errorf("%s line %d: %v", name, lines, err)
+			}
+			if span.Name != "work" {
+				t.Errorf("%s line %d span = %q, want work", name, lines, span.Name)
+			}
+			lines++
+		}
+		_ = f.Close()
+		if lines != 1 {
+			t.Errorf("%s holds %d spans, want 1", name, lines)
+		}
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("%s.2 exists beyond the max backups, err=%v", path, err)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotate.log")
+	w, err := newRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: path, want: "fourth\n"},
+		{name: path + ".1", want: "third\n"},
+		{name: path + ".2", want: "second\n"},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.name), func(t *testing.T) {
+			got, err := os.ReadFile(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportOTLPHTTP(t *testing.T) {
+	tests := []struct {
+		name string
+		tls  bool
+	}{
+		{name: "plain text"},
+		{name: "tls", tls: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan http.Header, 1)
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v1/traces" {
+					received <- r.Header.Clone()
+				}
+				w.Header().Set("Content-Type", "application/x-protobuf")
+				w.WriteHeader(http.StatusOK)
+			})
+			var collector *httptest.Server
+			opts := []OTLPOption{OTLPHeaders(map[string]string{"Authorization": "Bearer secret"})}
+			if tt.tls {
+				collector = httptest.NewTLSServer(handler)
+				opts = append(opts, OTLPTLSConfig(collector.Client().Transport.(*http.Transport).TLSClientConfig))
+			} else {
+				collector = httptest.NewServer(handler)
+			}
+			defer collector.Close()
+			runTraced(t, 1, ExportOTLPHTTP(collector.Listener.Addr().String(), opts...))
+			select {
+			case header := <-received:
+				if got := header.Get("Authorization"); got != "Bearer secret" {
+					t.Errorf("Authorization = %q, want the configured header", got)
+				}
+			default:
+				t.Fatal("no spans exported to the collector")
+			}
+		})
+	}
+}
+
+func TestExportZipkin(t *testing.T) {
+	received := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+	runTraced(t, 1, ExportZipkin(collector.URL+"/api/v2/spans"))
+	select {
+	case body := <-received:
+		if !strings.Contains(body, `"name":"work"`) {
+			t.Errorf("zipkin body = %s, want the span work", body)
+		}
+	default:
+		t.Fatal("no spans exported to the collector")
+	}
+}
@@ -0,0 +1,97 @@
+package net
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// rotatingFile is an io.WriteCloser which rotates the file at path once it
+// grows over maxSize bytes, a single write is never split across files.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	w := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingFile) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "open trace file")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "stat trace file")
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1, the oldest
+// backup beyond maxBackups is removed.
+func (w *rotatingFile) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return errors.Wrap(err, "close trace file")
+	}
+	w.file = nil
+	if w.maxBackups > 0 {
+		_ = os.Remove(w.backup(w.maxBackups))
+		for i := w.maxBackups - 1; i > 0; i-- {
+			if err := os.Rename(w.backup(i), w.backup(i+1)); err != nil && !os.IsNotExist(err) {
+				return errors.Wrap(err, "rotate trace file")
+			}
+		}
+		if err := os.Rename(w.path, w.backup(1)); err != nil {
+			return errors.Wrap(err, "rotate trace file")
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return errors.Wrap(err, "rotate trace file")
+	}
+	return w.open()
+}
+
+func (w *rotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", w.path, i)
+}
+
+func (w *rotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,101 @@
+package net
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// The propagators of Propagators, named after the values of
+// OTEL_PROPAGATORS.
+const (
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+	PropagatorB3           = "b3"
+	PropagatorB3Multi      = "b3multi"
+	PropagatorJaeger       = "jaeger"
+)
+
+// DefaultPropagators are the W3C trace context and baggage plus the B3
+// multiple headers of the previous releases.
+var DefaultPropagators = []string{PropagatorTraceContext, PropagatorBaggage, PropagatorB3Multi}
+
+// extractPropagators extract the trace context of every known format, the
+// ones configured are put at the end so that theirs win.
+var extractPropagators = []string{PropagatorB3, PropagatorJaeger, PropagatorTraceContext, PropagatorBaggage}
+
+func newPropagator(name string) (propagation.TextMapPropagator, error) {
+	switch name {
+	case PropagatorTraceContext:
+		return propagation.TraceContext{}, nil
+	case PropagatorBaggage:
+		return propagation.Baggage{}, nil
+	case PropagatorB3:
+		return b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)), nil
+	case PropagatorB3Multi:
+		return b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)), nil
+	case PropagatorJaeger:
+		return jaeger.Jaeger{}, nil
+	}
+	return nil, errors.Errorf("unknown propagator %q", name)
+}
+
+// injectExtract injects with one propagator and extracts with another.
+type injectExtract struct {
+	inject  propagation.TextMapPropagator
+	extract propagation.TextMapPropagator
+}
+
+func (p injectExtract) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	p.inject.Inject(ctx, carrier)
+}
+
+func (p injectExtract) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return p.extract.Extract(ctx, carrier)
+}
+
+func (p injectExtract) Fields() []string {
+	return p.inject.Fields()
+}
+
+// NewPropagator returns a propagator which injects the trace context with
+// the named propagators and extracts it from any known format, the named
+// ones take precedence.
+func NewPropagator(names ...string) (propagation.TextMapPropagator, error) {
+	inject := make([]propagation.TextMapPropagator, 0, len(names))
+	configured := make(map[string]bool, len(names))
+	for _, name := range names {
+		p, err := newPropagator(name)
+		if err != nil {
+			return nil, err
+		}
+		inject = append(inject, p)
+		configured[name] = true
+	}
+	extract := make([]propagation.TextMapPropagator, 0, len(extractPropagators)+len(inject))
+	for _, name := range extractPropagators {
+		// b3 extracts both encodings
+		if configured[name] || name == PropagatorB3 && configured[PropagatorB3Multi] {
+			continue
+		}
+		p, _ := newPropagator(name)
+		extract = append(extract, p)
+	}
+	extract = append(extract, inject...)
+	return injectExtract{
+		inject:  propagation.NewCompositeTextMapPropagator(inject...),
+		extract: propagation.NewCompositeTextMapPropagator(extract...),
+	}, nil
+}
+
+// propagator returns the propagator of Propagators, DefaultPropagators when
+// none are given.
+func (o options) propagator() (propagation.TextMapPropagator, error) {
+	if len(o.propagators) == 0 {
+		return NewPropagator(DefaultPropagators...)
+	}
+	return NewPropagator(o.propagators...)
+}
@@ -0,0 +1,121 @@
+package net
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	w3cTraceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+	jaegerTraceID = "00000000000000000000000000000abc"
+)
+
+func TestNewPropagator_Inject(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex(w3cTraceID)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	member, _ := baggage.NewMember("user", "jack")
+	bag, _ := baggage.New(member)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "default", names: DefaultPropagators, want: []string{"Baggage", "Traceparent", "X-B3-Sampled", "X-B3-Spanid", "X-B3-Traceid"}},
+		{name: "b3 single", names: []string{PropagatorB3}, want: []string{"B3"}},
+		{name: "jaeger", names: []string{PropagatorJaeger}, want: []string{"Uber-Trace-Id"}},
+		{name: "unknown", names: []string{"xray"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPropagator(tt.names...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPropagator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			header := http.Header{}
+			p.Inject(ctx, propagation.HeaderCarrier(header))
+			var got []string
+			for k := range header {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("injected headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPropagator_Extract(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  []string
+		header map[string]string
+		want   string
+	}{
+		{
+			name:   "w3c",
+			header: map[string]string{"traceparent": "00-" + w3cTraceID + "-00f067aa0ba902b7-01"},
+			want:   w3cTraceID,
+		},
+		{
+			name:   "b3 single",
+			header: map[string]string{"b3": w3cTraceID + "-00f067aa0ba902b7-1"},
+			want:   w3cTraceID,
+		},
+		{
+			name:   "b3 multi",
+			header: map[string]string{"x-b3-traceid": w3cTraceID, "x-b3-spanid": "00f067aa0ba902b7", "x-b3-sampled": "1"},
+			want:   w3cTraceID,
+		},
+		{
+			name:   "jaeger",
+			header: map[string]string{"uber-trace-id": "0000000000000abc:0000000000000def:0:1"},
+			want:   jaegerTraceID,
+		},
+		{
+			name:  "the configured propagator wins",
+			names: []string{PropagatorJaeger},
+			header: map[string]string{
+				"traceparent":   "00-" + w3cTraceID + "-00f067aa0ba902b7-01",
+				"uber-trace-id": "0000000000000abc:0000000000000def:0:1",
+			},
+			want: jaegerTraceID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := tt.names
+			if len(names) == 0 {
+				names = DefaultPropagators
+			}
+			p, err := NewPropagator(names...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			ctx := p.Extract(context.Background(), propagation.HeaderCarrier(header))
+			if got := TraceID(ctx); got != tt.want {
+				t.Errorf("extracted trace id = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
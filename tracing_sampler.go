@@ -0,0 +1,152 @@
+package net
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// neverSampled are the spans of the health checks and metrics scrapes, they
+// are dropped whatever the sampler and the parent decide.
+var neverSampled = []string{
+	"/healthC",
+	"/metrics",
+	"grpc.health.v1.Health/*",
+}
+
+// SampleRule decides the spans matching Name, and Method when it is set,
+// with Sampler.
+type SampleRule struct {
+	// Name of the span, which is the route template of the HTTP spans, e.g.
+	// /users/:id, and the full method of the gRPC spans without the leading
+	// slash, e.g. helloworld.Greeter/SayHello. A trailing * matches any
+	// suffix.
+	Name string
+	// Method of the HTTP requests, e.g. GET, any method when empty.
+	Method string
+	// Sampler decides the matched spans, e.g. sdkTrace.NeverSample().
+	Sampler sdkTrace.Sampler
+}
+
+func (r SampleRule) match(p sdkTrace.SamplingParameters) bool {
+	if strings.HasSuffix(r.Name, "*") {
+		if !strings.HasPrefix(p.Name, strings.TrimSuffix(r.Name, "*")) {
+			return false
+		}
+	} else if p.Name != r.Name {
+		return false
+	}
+	if r.Method == "" {
+		return true
+	}
+	for _, kv := range p.Attributes {
+		if kv.Key == semConv.HTTPMethodKey {
+			return strings.EqualFold(kv.Value.AsString(), r.Method)
+		}
+	}
+	return false
+}
+
+// ruleSampler decides with the first matching rule, the other spans are
+// left to fallback.
+type ruleSampler struct {
+	rules    []SampleRule
+	fallback sdkTrace.Sampler
+}
+
+func (s ruleSampler) ShouldSample(p sdkTrace.SamplingParameters) sdkTrace.SamplingResult {
+	for _, r := range s.rules {
+		if r.match(p) {
+			return r.Sampler.ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s ruleSampler) Description() string {
+	return fmt.Sprintf("RuleSampler{rules:%d,fallback:%s}", len(s.rules), s.fallback.Description())
+}
+
+// RuleSampler returns a sampler which decides the spans with the first of
+// rules they match and the others with fallback. The rules are applied
+// before the decision of the parent, wrap their samplers with
+// sdkTrace.ParentBased to follow it.
+func RuleSampler(fallback sdkTrace.Sampler, rules ...SampleRule) sdkTrace.Sampler {
+	return ruleSampler{rules: rules, fallback: fallback}
+}
+
+// rateLimitSampler is a token bucket which refills perSecond tokens every
+// second and holds as many, at least one.
+type rateLimitSampler struct {
+	perSecond float64
+	burst     float64
+	mu        sync.Mutex
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+// RateLimitSampler returns a sampler which samples at most perSecond spans
+// every second, the bursts are capped at perSecond too.
+func RateLimitSampler(perSecond float64) sdkTrace.Sampler {
+	burst := math.Max(perSecond, 1)
+	return &rateLimitSampler{perSecond: perSecond, burst: burst, tokens: burst, now: time.Now}
+}
+
+func (s *rateLimitSampler) ShouldSample(p sdkTrace.SamplingParameters) sdkTrace.SamplingResult {
+	decision := sdkTrace.Drop
+	if s.take() {
+		decision = sdkTrace.RecordAndSample
+	}
+	return sdkTrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *rateLimitSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if !s.last.IsZero() {
+		s.tokens += now.Sub(s.last).Seconds() * s.perSecond
+		if s.tokens > s.burst {
+			s.tokens = s.burst
+		}
+	}
+	s.last = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+func (s *rateLimitSampler) Description() string {
+	return fmt.Sprintf("RateLimitSampler{%g}", s.perSecond)
+}
+
+// sampler builds the sampler of the tracer provider: the health checks and
+// metrics scrapes are never sampled, then the rules and the root sampler
+// decide, the latter follows the parent unless SampleParentBased(false).
+func (o options) sampler() sdkTrace.Sampler {
+	root := o.rootSampler
+	if root == nil {
+		root = sdkTrace.TraceIDRatioBased(1.0)
+	}
+	if o.parentBased {
+		root = sdkTrace.ParentBased(root)
+	}
+	rules := make([]SampleRule, 0, len(neverSampled)+len(o.sampleRules))
+	for _, name := range neverSampled {
+		rules = append(rules, SampleRule{Name: name, Sampler: sdkTrace.NeverSample()})
+	}
+	rules = append(rules, o.sampleRules...)
+	return RuleSampler(root, rules...)
+}
@@ -0,0 +1,107 @@
+package net
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOptions_Sampler(t *testing.T) {
+	sampledParent := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+	tests := []struct {
+		name   string
+		opts   []Option
+		parent context.Context
+		span   string
+		method string
+		want   sdkTrace.SamplingDecision
+	}{
+		{name: "default samples every trace", span: "/users/:id", want: sdkTrace.RecordAndSample},
+		{name: "http health check", span: "/healthC", want: sdkTrace.Drop},
+		{name: "metrics", span: "/metrics", want: sdkTrace.Drop},
+		{name: "grpc health check", span: "grpc.health.v1.Health/Check", want: sdkTrace.Drop},
+		{name: "health check of a sampled parent", span: "grpc.health.v1.Health/Watch", parent: sampledParent, want: sdkTrace.Drop},
+		{name: "never", opts: []Option{SampleNever()}, span: "/users/:id", want: sdkTrace.Drop},
+		{name: "ratio", opts: []Option{SampleRatio(0)}, span: "/users/:id", want: sdkTrace.Drop},
+		{name: "parent based", opts: []Option{SampleNever()}, parent: sampledParent, span: "/users/:id", want: sdkTrace.RecordAndSample},
+		{name: "ignore the parent", opts: []Option{SampleNever(), SampleParentBased(false)}, parent: sampledParent, span: "/users/:id", want: sdkTrace.Drop},
+		{
+			name:   "route and method rule",
+			opts:   []Option{SampleRules(SampleRule{Name: "/users/*", Method: http.MethodPost, Sampler: sdkTrace.NeverSample()})},
+			span:   "/users/:id",
+			method: http.MethodPost,
+			want:   sdkTrace.Drop,
+		},
+		{
+			name:   "rule of another method",
+			opts:   []Option{SampleRules(SampleRule{Name: "/users/*", Method: http.MethodPost, Sampler: sdkTrace.NeverSample()})},
+			span:   "/users/:id",
+			method: http.MethodGet,
+			want:   sdkTrace.RecordAndSample,
+		},
+		{
+			name: "grpc method rule",
+			opts: []Option{SampleNever(), SampleRules(SampleRule{Name: "helloworld.Greeter/SayHello", Sampler: sdkTrace.AlwaysSample()})},
+			span: "helloworld.Greeter/SayHello",
+			want: sdkTrace.RecordAndSample,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(tt.opts...)
+			parent := tt.parent
+			if parent == nil {
+				parent = context.Background()
+			}
+			p := sdkTrace.SamplingParameters{
+				ParentContext: parent,
+				TraceID:       trace.TraceID{0xff},
+				Name:          tt.span,
+			}
+			if tt.method != "" {
+				p.Attributes = append(p.Attributes, semConv.HTTPMethodKey.String(tt.method))
+			}
+			if got := app.opts.sampler().ShouldSample(p).Decision; got != tt.want {
+				t.Errorf("ShouldSample(%s) = %v, want %v", tt.span, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitSampler(t *testing.T) {
+	now := time.Now()
+	s := RateLimitSampler(2).(*rateLimitSampler)
+	s.now = func() time.Time { return now }
+	sample := func() bool {
+		return s.ShouldSample(sdkTrace.SamplingParameters{ParentContext: context.Background()}).Decision == sdkTrace.RecordAndSample
+	}
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    []bool
+	}{
+		{name: "burst", want: []bool{true, true, false}},
+		{name: "refill", elapsed: 500 * time.Millisecond, want: []bool{true, false}},
+		{name: "capped", elapsed: time.Minute, want: []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.elapsed)
+			for i, want := range tt.want {
+				if got := sample(); got != want {
+					t.Errorf("span %d sampled = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
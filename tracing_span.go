@@ -0,0 +1,116 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// StartSpan starts a span named name as a child of the span in ctx, with the
+// tracer of the app found in ctx. The span must be ended by the caller:
+//
+//	ctx, span := net.StartSpan(ctx, "query user", net.KeyValue{Key: "db.table", Value: "user"})
+//	defer span.End()
+func StartSpan(ctx context.Context, name string, attrs ...KeyValue) (context.Context, trace.Span) {
+	return Tracer(ctx).Start(ctx, name, trace.WithAttributes(attributes(attrs)...))
+}
+
+// SetAttributes sets attrs on the span in ctx.
+func SetAttributes(ctx context.Context, attrs ...KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attributes(attrs)...)
+}
+
+// AddEvent adds an event named name to the span in ctx.
+func AddEvent(ctx context.Context, name string, attrs ...KeyValue) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attributes(attrs)...))
+}
+
+// stackTracer is implemented by the errors of github.com/pkg/errors.
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// RecordError records err as an exception event of the span in ctx and sets
+// the status of the span from err, see SetStatus. The stack trace is the one
+// of the innermost error of github.com/pkg/errors, else the one of the
+// caller.
+func RecordError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	var stack stackTracer
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if st, ok := e.(stackTracer); ok {
+			stack = st
+		}
+	}
+	if stack != nil {
+		span.RecordError(err, trace.WithAttributes(semConv.ExceptionStacktraceKey.String(fmt.Sprintf("%+v", stack.StackTrace()))))
+	} else {
+		span.RecordError(err, trace.WithStackTrace(true))
+	}
+	SetStatus(ctx, err)
+}
+
+// SetStatus sets the status of the span in ctx from err: ok for a nil err,
+// else an error with the message of err. The gRPC status errors, wrapped or
+// not, give their message and code.
+func SetStatus(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err == nil {
+		span.SetStatus(codes.Ok, "")
+		return
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		s := se.GRPCStatus()
+		span.SetAttributes(semConv.RPCGRPCStatusCodeKey.Int64(int64(s.Code())))
+		span.SetStatus(codes.Error, s.Message())
+		return
+	}
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Baggage returns the value of the W3C baggage member key of ctx, empty when
+// there is none.
+func Baggage(ctx context.Context, key string) string {
+	return baggage.FromContext(ctx).Member(key).Value()
+}
+
+// SetBaggage returns a copy of ctx with the W3C baggage member key set to
+// value, it goes out with the trace context of the outgoing requests. The
+// values with spaces, commas, semicolons, backslashes, quotes or non ASCII
+// characters are refused, the receivers could not extract them.
+func SetBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	member, err := baggage.NewMember(key, url.QueryEscape(value))
+	if err != nil {
+		return ctx, errors.Wrapf(err, "baggage member %q", key)
+	}
+	if _, err := baggage.Parse(member.String()); err != nil {
+		return ctx, errors.Wrapf(err, "baggage member %q can not be propagated", key)
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, errors.Wrapf(err, "baggage member %q", key)
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+func attributes(kvs []KeyValue) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		if kv.Key != "" {
+			attrs = append(attrs, attribute.String(kv.Key, kv.Value))
+		}
+	}
+	return attrs
+}
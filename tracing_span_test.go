@@ -0,0 +1,201 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	otelCodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordedContext returns the context of an app tracing with recorder.
+func recordedContext(recorder *tracetest.SpanRecorder) context.Context {
+	app := New(Name("spans"))
+	app.tracerProvider = sdkTrace.NewTracerProvider(sdkTrace.WithSpanProcessor(recorder))
+	return NewContext(context.Background(), app)
+}
+
+func TestStartSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	ctx := recordedContext(recorder)
+	ctx, parent := StartSpan(ctx, "parent")
+	ctx, child := StartSpan(ctx, "query user", KeyValue{Key: "db.table", Value: "user"}, KeyValue{Value: "no key"})
+	SetAttributes(ctx, KeyValue{Key: "db.rows", Value: "1"})
+	AddEvent(ctx, "cache miss", KeyValue{Key: "key", Value: "user:1"})
+	child.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended spans = %d, want 2", len(spans))
+	}
+	got := spans[0]
+	if got.Name() != "query user" {
+		t.Errorf("span name = %q, want query user", got.Name())
+	}
+	if got.Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Errorf("span parent = %s, want %s", got.Parent().SpanID(), spans[1].SpanContext().SpanID())
+	}
+	want := []attribute.KeyValue{attribute.String("db.table", "user"), attribute.String("db.rows", "1")}
+	if attrs := got.Attributes(); fmt.Sprint(attrs) != fmt.Sprint(want) {
+		t.Errorf("span attributes = %v, want %v", attrs, want)
+	}
+	if events := got.Events(); len(events) != 1 || events[0].Name != "cache miss" {
+		t.Errorf("span events = %v, want [cache miss]", events)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantStack string
+	}{
+		{
+			name:      "pkg errors",
+			err:       errors.Wrap(errors.New("no rows"), "query user"),
+			wantStack: "net.TestRecordError",
+		},
+		{
+			name:      "caller stack",
+			err:       fmt.Errorf("no rows"),
+			wantStack: "net.RecordError",
+		},
+		{
+			name: "nil",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			ctx, span := StartSpan(recordedContext(recorder), "query")
+			RecordError(ctx, tt.err)
+			span.End()
+			got := recorder.Ended()[0]
+			if tt.err == nil {
+				if len(got.Events()) != 0 || got.Status().Code != otelCodes.Unset {
+					t.Errorf("span events = %v status = %v, want none", got.Events(), got.Status())
+				}
+				return
+			}
+			if len(got.Events()) != 1 {
+				t.Fatalf("span events = %d, want 1", len(got.Events()))
+			}
+			attrs := make(map[attribute.Key]string)
+			for _, kv := range got.Events()[0].Attributes {
+				attrs[kv.Key] = kv.Value.AsString()
+			}
+			if attrs[semConv.ExceptionMessageKey] != tt.err.Error() {
+				t.Errorf("exception message = %q, want %q", attrs[semConv.ExceptionMessageKey], tt.err.Error())
+			}
+			if stack := attrs[semConv.ExceptionStacktraceKey]; !strings.Contains(stack, tt.wantStack) {
+				t.Errorf("exception stack trace = %q, want it to contain %s", stack, tt.wantStack)
+			}
+			if got.Status().Code != otelCodes.Error {
+				t.Errorf("span status = %v, want error", got.Status())
+			}
+		})
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    otelCodes.Code
+		wantMessage string
+		wantGRPC    bool
+	}{
+		{name: "nil", wantCode: otelCodes.Ok},
+		{name: "error", err: errors.New("no rows"), wantCode: otelCodes.Error, wantMessage: "no rows"},
+		{
+			name:        "grpc status",
+			err:         status.Error(codes.NotFound, "user not found"),
+			wantCode:    otelCodes.Error,
+			wantMessage: "user not found",
+			wantGRPC:    true,
+		},
+		{
+			name:        "wrapped grpc status",
+			err:         errors.Wrap(status.Error(codes.Unavailable, "no backend"), "get user"),
+			wantCode:    otelCodes.Error,
+			wantMessage: "no backend",
+			wantGRPC:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			ctx, span := StartSpan(recordedContext(recorder), "call")
+			SetStatus(ctx, tt.err)
+			span.End()
+			got := recorder.Ended()[0]
+			if got.Status().Code != tt.wantCode || got.Status().Description != tt.wantMessage {
+				t.Errorf("span status = %v, want %v %q", got.Status(), tt.wantCode, tt.wantMessage)
+			}
+			var code int64 = -1
+			for _, kv := range got.Attributes() {
+				if kv.Key == semConv.RPCGRPCStatusCodeKey {
+					code = kv.Value.AsInt64()
+				}
+			}
+			if wantCode := int64(status.Code(errors.Cause(tt.err))); tt.wantGRPC && code != wantCode {
+				t.Errorf("grpc status code = %d, want %d", code, wantCode)
+			} else if !tt.wantGRPC && code != -1 {
+				t.Errorf("grpc status code = %d, want none", code)
+			}
+		})
+	}
+}
+
+func TestBaggage(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{name: "plain", key: "tenant", value: "acme"},
+		{name: "escaped", key: "path", value: "/users/1%20"},
+		{name: "not propagated", key: "user", value: "jack smith", wantErr: true},
+		{name: "invalid key", key: "bad key", value: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := SetBaggage(context.Background(), "region", "eu")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, err = SetBaggage(ctx, tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetBaggage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if Baggage(ctx, "region") != "eu" {
+				t.Errorf("Baggage(region) = %q, want eu", Baggage(ctx, "region"))
+			}
+			if err != nil {
+				return
+			}
+			if got := Baggage(ctx, tt.key); got != tt.value {
+				t.Errorf("Baggage(%s) = %q, want %q", tt.key, got, tt.value)
+			}
+			// the member goes through the baggage header
+			header := http.Header{}
+			propagation.Baggage{}.Inject(ctx, propagation.HeaderCarrier(header))
+			extracted := propagation.Baggage{}.Extract(context.Background(), propagation.HeaderCarrier(header))
+			if got := Baggage(extracted, tt.key); got != tt.value {
+				t.Errorf("propagated Baggage(%s) = %q, want %q", tt.key, got, tt.value)
+			}
+		})
+	}
+}
@@ -2,9 +2,13 @@ package net
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
+	"go.opentelemetry.io/otel"
 	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func Test_configAgent(t *testing.T) {
@@ -23,11 +27,8 @@ func Test_configAgent(t *testing.T) {
 	}
 	app := New(opts...)
 	type args struct {
-		ctx        context.Context
-		agentAddr  string
-		service    string
-		version    string
-		attributes []KeyValue
+		ctx  context.Context
+		opts options
 	}
 	tests := []struct {
 		name    string
@@ -38,11 +39,8 @@ func Test_configAgent(t *testing.T) {
 		{
 			name: "test config agent",
 			args: args{
-				ctx:        app.ctx,
-				agentAddr:  app.opts.agentAddr,
-				service:    app.opts.name,
-				version:    app.opts.version,
-				attributes: app.opts.attributes,
+				ctx:  app.ctx,
+				opts: app.opts,
 			},
 			want:    nil,
 			wantErr: false,
@@ -50,7 +48,7 @@ func Test_configAgent(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := configAgent(tt.args.ctx, tt.args.agentAddr, tt.args.service, tt.args.version, tt.args.attributes...)
+			got, err := configAgent(tt.args.ctx, tt.args.opts)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -64,3 +62,89 @@ func Test_configAgent(t *testing.T) {
 		})
 	}
 }
+
+func TestTracing(t *testing.T) {
+	env := KeyValue{Key: "env", Value: "dev"}
+	name := KeyValue{Key: "name", Value: "jack"}
+	tests := []struct {
+		name          string
+		opts          []Option
+		wantExporters int
+		wantAttrs     []KeyValue
+	}{
+		{
+			name:          "agent address",
+			opts:          []Option{Tracing("127.0.0.1:4317", name)},
+			wantExporters: 1,
+			wantAttrs:     []KeyValue{name},
+		},
+		{
+			name:      "empty address exports nothing",
+			opts:      []Option{Tracing("", name)},
+			wantAttrs: []KeyValue{name},
+		},
+		{
+			name:          "attributes given before are kept",
+			opts:          []Option{TraceAttributes(env), Tracing("127.0.0.1:4317", name)},
+			wantExporters: 1,
+			wantAttrs:     []KeyValue{env, name},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(tt.opts...)
+			if got := len(app.opts.exporters); got != tt.wantExporters {
+				t.Errorf("exporters = %d, want %d", got, tt.wantExporters)
+			}
+			if !reflect.DeepEqual(app.opts.attributes, tt.wantAttrs) {
+				t.Errorf("attributes = %v, want %v", app.opts.attributes, tt.wantAttrs)
+			}
+		})
+	}
+}
+
+func TestApp_TracerProvider(t *testing.T) {
+	// the error handler can not be restored, it only logs
+	globalProvider, globalPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	tests := []struct {
+		name   string
+		global bool
+	}{
+		{name: "foo"},
+		{name: "bar"},
+		{name: "global", global: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				otel.SetTracerProvider(globalProvider)
+				otel.SetTextMapPropagator(globalPropagator)
+			}()
+			exp := tracetest.NewInMemoryExporter()
+			var (
+				app *App
+				tp  trace.TracerProvider
+			)
+			work := AfterStart(func(ctx context.Context) error {
+				_, span := Tracer(ctx).Start(ctx, "work")
+				span.End()
+				tp = otel.GetTracerProvider()
+				return app.Stop()
+			})
+			app = New(Name(tt.name), ExportMemory(exp), GlobalTracing(tt.global), work)
+			if err := app.Run(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range exp.GetSpans() {
+				got = append(got, s.Name)
+			}
+			if len(got) != 1 || got[0] != "work" {
+				t.Errorf("recorded spans = %v, want [work]", got)
+			}
+			if installed := tp == app.TracerProvider(); installed != tt.global {
+				t.Errorf("global tracer provider installed = %v, want %v", installed, tt.global)
+			}
+		})
+	}
+}
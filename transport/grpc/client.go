@@ -5,12 +5,14 @@ import (
 	"fmt"
 
 	grpcPrometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
-	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
 	prom "github.com/prometheus/client_golang/prometheus"
-	"github.com/weiqiangxu/user/config"
+	netApp "github.com/weiqiangxu/net"
+	"github.com/weiqiangxu/net/tool"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/credentials"
 	grpcInsecure "google.golang.org/grpc/credentials/insecure"
 )
 
@@ -20,9 +22,22 @@ func Dial(ctx context.Context, opts ...ClientOption) (*grpc.ClientConn, error) {
 	for _, o := range opts {
 		o(&options)
 	}
-	if options.tracing {
-		options.unaryInterceptors = append(options.unaryInterceptors, otelgrpc.UnaryClientInterceptor())
-		options.streamInterceptors = append(options.streamInterceptors, otelgrpc.StreamClientInterceptor())
+	tracing := options.tracing || options.tracerProvider != nil
+	if info, ok := netApp.FromContext(ctx); ok {
+		if options.serviceName == "" {
+			options.serviceName = info.Name()
+		}
+		if options.tracerProvider == nil {
+			options.tracerProvider = info.TracerProvider()
+		}
+		if options.propagator == nil {
+			options.propagator = info.Propagator()
+		}
+	}
+	if tracing {
+		traceOpts := traceOptions(options.tracerProvider, options.propagator)
+		options.unaryInterceptors = append(options.unaryInterceptors, otelgrpc.UnaryClientInterceptor(traceOpts...))
+		options.streamInterceptors = append(options.streamInterceptors, otelgrpc.StreamClientInterceptor(traceOpts...))
 	}
 	grpcOpts := []grpc.DialOption{
 		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"LoadBalancingPolicy": %q}`, roundrobin.Name)),
@@ -30,30 +45,55 @@ func Dial(ctx context.Context, opts ...ClientOption) (*grpc.ClientConn, error) {
 		grpc.WithChainStreamInterceptor(options.streamInterceptors...),
 	}
 	if options.prometheus {
-		grpcPrometheus.EnableClientHandlingTimeHistogram(
-			WithGrpcHistogramName(config.Conf.Application.Name, "grpc_seconds"),
-		)
+		metrics, err := clientMetrics(ctx, options)
+		if err != nil {
+			return nil, err
+		}
 		list := []grpc.DialOption{
-			grpc.WithUnaryInterceptor(grpcPrometheus.UnaryClientInterceptor),
-			grpc.WithStreamInterceptor(grpcPrometheus.StreamClientInterceptor),
+			grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor()),
+			grpc.WithStreamInterceptor(metrics.StreamClientInterceptor()),
 		}
 		grpcOpts = append(grpcOpts, list...)
 	}
-	if options.insecure {
+	if options.tlsConf != nil {
+		grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(credentials.NewTLS(options.tlsConf)))
+	} else if options.insecure {
 		grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(grpcInsecure.NewCredentials()))
 	}
-	if options.tracerInterceptor {
-		list := []grpc.DialOption{
-			grpc.WithUnaryInterceptor(ClientInterceptor(options.tracer)),
-		}
-		grpcOpts = append(grpcOpts, list...)
-	}
 	if len(options.grpcOpts) > 0 {
 		grpcOpts = append(grpcOpts, options.grpcOpts...)
 	}
 	return grpc.DialContext(ctx, options.endpoint, grpcOpts...)
 }
 
+// clientMetrics registers the client metrics on the registerer given by
+// WithRegisterer, else on the registry of the app found in ctx. The series
+// carry the service name as the service label, the clients dialed with the
+// same one share them. Outside of an app the default metrics of
+// go-grpc-prometheus are used.
+func clientMetrics(ctx context.Context, options clientOptions) (*grpcPrometheus.ClientMetrics, error) {
+	reg := options.registerer
+	if reg == nil {
+		reg = registererFromContext(ctx)
+	}
+	if reg == nil {
+		grpcPrometheus.EnableClientHandlingTimeHistogram(WithGrpcHistogramName(options.serviceName, "grpc_seconds"))
+		return grpcPrometheus.DefaultClientMetrics, nil
+	}
+	labels := prom.Labels{"service": options.serviceName}
+	metrics := grpcPrometheus.NewClientMetrics(grpcPrometheus.WithConstLabels(labels))
+	metrics.EnableClientHandlingTimeHistogram(grpcPrometheus.WithHistogramConstLabels(labels))
+	registered, err := tool.Register(reg, metrics)
+	if err != nil {
+		return nil, errors.Wrap(err, "register grpc client metrics")
+	}
+	metrics, ok := registered.(*grpcPrometheus.ClientMetrics)
+	if !ok {
+		return nil, errors.Errorf("grpc client metrics are registered by a %T", registered)
+	}
+	return metrics, nil
+}
+
 // WithGrpcHistogramName change prometheus histogramName
 func WithGrpcHistogramName(namespace string, name string) grpcPrometheus.HistogramOption {
 	return func(o *prom.HistogramOpts) {
@@ -61,16 +101,3 @@ func WithGrpcHistogramName(namespace string, name string) grpcPrometheus.Histogr
 		o.Name = name
 	}
 }
-
-// ClientInterceptor grpc client
-func ClientInterceptor(tracer opentracing.Tracer) grpc.UnaryClientInterceptor {
-	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		parentSpan := ctx.Value("span")
-		if parentSpan != nil {
-			parentSpanContext := parentSpan.(opentracing.SpanContext)
-			child := tracer.StartSpan(method, opentracing.ChildOf(parentSpanContext))
-			defer child.Finish()
-		}
-		return invoker(ctx, method, req, reply, cc, opts...)
-	}
-}
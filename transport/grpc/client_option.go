@@ -1,21 +1,28 @@
 package grpc
 
 import (
-	"github.com/opentracing/opentracing-go"
+	"crypto/tls"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 )
 
 // clientOptions is gRPC Client
 type clientOptions struct {
 	endpoint           string
+	serviceName        string
 	unaryInterceptors  []grpc.UnaryClientInterceptor
 	streamInterceptors []grpc.StreamClientInterceptor
 	grpcOpts           []grpc.DialOption
 	tracing            bool
 	insecure           bool
+	tlsConf            *tls.Config
 	prometheus         bool
-	tracer             opentracing.Tracer
-	tracerInterceptor  bool
+	registerer         prometheus.Registerer
+	tracerProvider     trace.TracerProvider
+	propagator         propagation.TextMapPropagator
 }
 
 // ClientOption is gRPC client option.
@@ -28,11 +35,31 @@ func WithEndpoint(endpoint string) ClientOption {
 	}
 }
 
-// WithUnaryTraceInterceptor with client endpoint.
-func WithUnaryTraceInterceptor(tracer opentracing.Tracer) ClientOption {
+// WithServiceName with the service name of the client metrics, the service
+// label of their series, or the namespace of the handling time histogram of
+// the default metrics. It defaults to the name of the app found in the dial
+// context.
+func WithServiceName(name string) ClientOption {
+	return func(c *clientOptions) {
+		c.serviceName = name
+	}
+}
+
+// WithTracerProvider trace the calls with tp instead of the tracer provider
+// of the app found in the dial context, or the global one outside of an app.
+// It turns the tracing on.
+func WithTracerProvider(tp trace.TracerProvider) ClientOption {
+	return func(c *clientOptions) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithPropagator inject the trace context of the calls with p instead of the
+// propagator of the app found in the dial context, or the global one outside
+// of an app.
+func WithPropagator(p propagation.TextMapPropagator) ClientOption {
 	return func(c *clientOptions) {
-		c.tracer = tracer
-		c.tracerInterceptor = true
+		c.propagator = p
 	}
 }
 
@@ -43,6 +70,15 @@ func WithPrometheus(b bool) ClientOption {
 	}
 }
 
+// WithRegisterer register the client metrics on reg instead of the registry
+// of the app found in the dial context, or prometheus.DefaultRegisterer
+// outside of an app.
+func WithRegisterer(reg prometheus.Registerer) ClientOption {
+	return func(c *clientOptions) {
+		c.registerer = reg
+	}
+}
+
 // WithUnaryInterceptor returns a DialOption that specifies the interceptor for unary RPCs.
 func WithUnaryInterceptor(u ...grpc.UnaryClientInterceptor) ClientOption {
 	return func(c *clientOptions) {
@@ -70,3 +106,11 @@ func WithInSecure(insecure bool) ClientOption {
 		c.insecure = insecure
 	}
 }
+
+// WithTLSConfig with TLS config, it takes precedence over WithInSecure.
+// See tool.TLSConfig to load it from files or PEM bytes.
+func WithTLSConfig(conf *tls.Config) ClientOption {
+	return func(c *clientOptions) {
+		c.tlsConf = conf
+	}
+}
@@ -2,9 +2,24 @@ package grpc
 
 import (
 	"context"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	otelCodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
 )
 
 func TestDial(t *testing.T) {
@@ -45,3 +60,192 @@ func TestDial(t *testing.T) {
 		})
 	}
 }
+
+func TestDial_ServiceName(t *testing.T) {
+	// the clients of the services dialed on one registry are told apart by
+	// the service label
+	reg := prometheus.NewRegistry()
+	srv := NewServer(Address("127.0.0.1:0"))
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+	tests := []struct {
+		name    string
+		service string
+		calls   int
+	}{
+		{name: "first service", service: "user", calls: 1},
+		{name: "second service", service: "order", calls: 2},
+		{name: "same service again", service: "user", calls: 1},
+	}
+	want := make(map[string]float64)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := Dial(context.Background(), WithEndpoint(endpoint.Host), WithInSecure(true),
+				WithPrometheus(true), WithRegisterer(reg), WithServiceName(tt.service))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			for i := 0; i < tt.calls; i++ {
+				if _, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want[tt.service] += float64(tt.calls)
+			labels := map[string]string{"grpc_service": HealthcheckService, "grpc_method": "Check", "grpc_type": "unary", "service": tt.service}
+			for _, metric := range []string{"grpc_client_started_total", "grpc_client_handling_seconds"} {
+				if got, ok := gatherValue(t, reg, metric, labels); !ok || got != want[tt.service] {
+					t.Errorf("%s{service=%q} = %v %v, want %v", metric, tt.service, got, ok, want[tt.service])
+				}
+			}
+		})
+	}
+}
+
+// listServices lists the services with a reflection stream which ends
+// normally.
+func listServices(ctx context.Context, conn *grpc.ClientConn) error {
+	stream, err := grpc_reflection_v1alpha.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return err
+	}
+	req := &grpc_reflection_v1alpha.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1alpha.ServerReflectionRequest_ListServices{},
+	}
+	if err := stream.Send(req); err != nil {
+		return err
+	}
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func TestClientInterceptor(t *testing.T) {
+	incoming := make(chan metadata.MD, 1)
+	srv := NewServer(
+		Address("127.0.0.1:0"),
+		UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			incoming <- md
+			return handler(ctx, req)
+		}),
+		StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			md, _ := metadata.FromIncomingContext(ss.Context())
+			incoming <- md
+			return handler(srv, ss)
+		}),
+	)
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+
+	tests := []struct {
+		name       string
+		call       func(conn *grpc.ClientConn) error
+		service    string
+		method     string
+		wantCode   codes.Code
+		wantStatus otelCodes.Code
+	}{
+		{
+			name: "unary",
+			call: func(conn *grpc.ClientConn) error {
+				_, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+				return err
+			},
+			service:    HealthcheckService,
+			method:     "Check",
+			wantCode:   codes.OK,
+			wantStatus: otelCodes.Unset,
+		},
+		{
+			name: "unary error",
+			call: func(conn *grpc.ClientConn) error {
+				_, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+				return err
+			},
+			service:    HealthcheckService,
+			method:     "Check",
+			wantCode:   codes.NotFound,
+			wantStatus: otelCodes.Error,
+		},
+		{
+			name: "streaming",
+			call: func(conn *grpc.ClientConn) error {
+				return listServices(context.Background(), conn)
+			},
+			service:    "grpc.reflection.v1alpha.ServerReflection",
+			method:     "ServerReflectionInfo",
+			wantCode:   codes.OK,
+			wantStatus: otelCodes.Unset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdkTrace.NewTracerProvider(sdkTrace.WithSpanProcessor(recorder))
+			conn, err := Dial(context.Background(), WithEndpoint(endpoint.Host), WithInSecure(true),
+				WithTracerProvider(tp), WithPropagator(propagation.TraceContext{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			err = tt.call(conn)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("call code = %s, want %s", code, tt.wantCode)
+			}
+			md := <-incoming
+			// the span of a stream ends in the background
+			deadline := time.Now().Add(time.Second)
+			for len(recorder.Ended()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("ended spans = %d, want 1", len(spans))
+			}
+			span := spans[0]
+			if traceparent := md.Get("traceparent"); len(traceparent) == 0 || !strings.Contains(traceparent[0], span.SpanContext().TraceID().String()) {
+				t.Errorf("traceparent = %v, want the trace %s", traceparent, span.SpanContext().TraceID())
+			}
+			attrs := make(map[attribute.Key]attribute.Value)
+			for _, kv := range span.Attributes() {
+				attrs[kv.Key] = kv.Value
+			}
+			want := map[attribute.Key]string{
+				semConv.RPCSystemKey:  "grpc",
+				semConv.RPCServiceKey: tt.service,
+				semConv.RPCMethodKey:  tt.method,
+			}
+			for k, v := range want {
+				if got := attrs[k].AsString(); got != v {
+					t.Errorf("attribute %s = %q, want %q", k, got, v)
+				}
+			}
+			if got := attrs[semConv.RPCGRPCStatusCodeKey].AsInt64(); got != int64(tt.wantCode) {
+				t.Errorf("attribute %s = %d, want %d", semConv.RPCGRPCStatusCodeKey, got, tt.wantCode)
+			}
+			if got := span.Status().Code; got != tt.wantStatus {
+				t.Errorf("span status = %s, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
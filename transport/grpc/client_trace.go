@@ -0,0 +1,28 @@
+package grpc
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// ClientInterceptor returns a unary client interceptor which traces the calls
+// with tp, or the global tracer provider when tp is nil. The trace context
+// goes out in the metadata with the global propagator, the spans carry the
+// rpc.* attributes and an error status for the failed calls. Dial traces
+// with the tracer provider and propagator of the app instead.
+func ClientInterceptor(tp trace.TracerProvider) grpc.UnaryClientInterceptor {
+	return otelgrpc.UnaryClientInterceptor(traceOptions(tp, nil)...)
+}
+
+// StreamClientInterceptor is the ClientInterceptor of the streaming calls,
+// the span ends with the stream and records the messages sent and received.
+func StreamClientInterceptor(tp trace.TracerProvider) grpc.StreamClientInterceptor {
+	return otelgrpc.StreamClientInterceptor(traceOptions(tp, nil)...)
+}
+
+// traceOptions with tp and p, the globals are used for the nil ones.
+func traceOptions(tp trace.TracerProvider, p propagation.TextMapPropagator) []otelgrpc.Option {
+	return []otelgrpc.Option{otelgrpc.WithTracerProvider(tp), otelgrpc.WithPropagators(p)}
+}
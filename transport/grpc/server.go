@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/url"
 	"sync"
@@ -11,14 +12,23 @@ import (
 
 	"github.com/weiqiangxu/net/tool"
 
+	grpcPrometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/weiqiangxu/common-config/logger"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
-var _ transport.Server = (*Server)(nil)
+var (
+	_ transport.Server     = (*Server)(nil)
+	_ transport.Readier    = (*Server)(nil)
+	_ transport.Endpointer = (*Server)(nil)
+)
 
 const (
 	DefaultNetProtocol = "tcp"
@@ -37,25 +47,43 @@ type Server struct {
 	address           string
 	endpoint          *url.URL
 	timeout           time.Duration
+	methodTimeouts    map[string]time.Duration
 	unaryInterceptor  []grpc.UnaryServerInterceptor
 	streamInterceptor []grpc.StreamServerInterceptor
 	grpcOpts          []grpc.ServerOption
+	tlsConf           *tls.Config
 	health            *health.Server
+	ready             chan struct{}
 	tracing           bool
+	tracerProvider    trace.TracerProvider
+	propagator        propagation.TextMapPropagator
+	traceUnary        grpc.UnaryServerInterceptor
+	traceStream       grpc.StreamServerInterceptor
 	recovery          bool
+	prometheus        bool
+	registerer        prometheus.Registerer
+	metrics           *grpcPrometheus.ServerMetrics
 }
 
 func NewServer(opts ...ServerOption) *Server {
-	server := &Server{network: DefaultNetProtocol, address: DefaultNetAddress, timeout: 1 * time.Second, health: health.NewServer()}
+	server := &Server{network: DefaultNetProtocol, address: DefaultNetAddress, timeout: 1 * time.Second, health: health.NewServer(), ready: make(chan struct{})}
 	for _, o := range opts {
 		o(server)
 	}
-	server.TraceDecorator()
+	// the deadline is set before the interceptors of the caller run and
+	// recovery is wrapped by metrics and tracing so that the panics are
+	// counted and traced too
+	server.TimeoutDecorator()
 	server.RecoveryDecorator()
+	server.PrometheusDecorator()
+	server.TraceDecorator()
 	grpcOpts := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(server.unaryInterceptor...),
 		grpc.ChainStreamInterceptor(server.streamInterceptor...),
 	}
+	if server.tlsConf != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(server.tlsConf)))
+	}
 	server.grpcOpts = append(server.grpcOpts, grpcOpts...)
 	server.Server = grpc.NewServer(server.grpcOpts...)
 	server.health.SetServingStatus(HealthcheckService, grpc_health_v1.HealthCheckResponse_SERVING)
@@ -65,6 +93,15 @@ func NewServer(opts ...ServerOption) *Server {
 }
 
 func (s *Server) Start(ctx context.Context) error {
+	if s.tracing {
+		s.initTracing(ctx)
+	}
+	if s.prometheus {
+		// the services are registered by now, their series start at zero
+		if err := s.initMetrics(ctx); err != nil {
+			return err
+		}
+	}
 	if _, err := s.endpointListen(); err != nil {
 		return err
 	}
@@ -74,32 +111,65 @@ func (s *Server) Start(ctx context.Context) error {
 	return s.Serve(s.listener)
 }
 
+// Endpoint return a real address to registry endpoint, the server starts
+// listening when it is called before Start.
+func (s *Server) Endpoint() (*url.URL, error) {
+	return s.endpointListen()
+}
+
+// Ready returns a channel which is closed once the server is listening.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Stop waits for the pending RPCs to finish, once ctx is done the server
+// closes all the connections forcibly.
 func (s *Server) Stop(ctx context.Context) error {
-	s.GracefulStop()
-	s.health.Shutdown()
 	logger.Info("[gRPC] server stopping")
-	return nil
+	s.health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		logger.Errorf("[gRPC] server graceful stop err=%v, force stopping", ctx.Err())
+		s.Server.Stop()
+		<-done
+		return ctx.Err()
+	}
 }
 
 // endpointListen return a real address to registry endpoint
 func (s *Server) endpointListen() (*url.URL, error) {
 	s.once.Do(func() {
-		lis, err := net.Listen(s.network, s.address)
-		if err != nil {
-			s.err = err
-			return
+		if s.listener == nil {
+			lis, err := net.Listen(s.network, s.address)
+			if err != nil {
+				s.err = err
+				return
+			}
+			s.listener = lis
+		} else {
+			// the listener given by Listener decides the address
+			s.address = s.listener.Addr().String()
 		}
-		addr, err := tool.Extract(s.address, s.listener)
+		endpoint, err := tool.Endpoint(SchemeOfGrpc, s.address, s.listener)
 		if err != nil {
-			err := lis.Close()
-			if err != nil {
-				logger.Errorf("close %s listener catch err=%v", s.address, err)
+			if e := s.listener.Close(); e != nil {
+				logger.Errorf("close %s listener catch err=%v", s.address, e)
 			}
 			s.err = err
 			return
 		}
-		s.listener = lis
-		s.endpoint = &url.URL{Scheme: SchemeOfGrpc, Host: addr}
+		if s.tlsConf != nil {
+			endpoint.RawQuery = "isSecure=true"
+		}
+		s.endpoint = endpoint
+		close(s.ready)
 	})
 	if s.err != nil {
 		return nil, s.err
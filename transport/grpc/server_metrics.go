@@ -0,0 +1,74 @@
+package grpc
+
+import (
+	"context"
+
+	grpcPrometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	netApp "github.com/weiqiangxu/net"
+	"github.com/weiqiangxu/net/tool"
+	"google.golang.org/grpc"
+)
+
+// PrometheusDecorator decorator the metrics interceptors to server, they are
+// put in front of the recovery so that the recovered panics are counted.
+// The metrics are registered when the server starts, see initMetrics.
+func (s *Server) PrometheusDecorator() {
+	if !s.prometheus {
+		return
+	}
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if s.metrics == nil {
+			return handler(ctx, req)
+		}
+		return s.metrics.UnaryServerInterceptor()(ctx, req, info, handler)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if s.metrics == nil {
+			return handler(srv, ss)
+		}
+		return s.metrics.StreamServerInterceptor()(srv, ss, info, handler)
+	}
+	s.unaryInterceptor = append([]grpc.UnaryServerInterceptor{unary}, s.unaryInterceptor...)
+	s.streamInterceptor = append([]grpc.StreamServerInterceptor{stream}, s.streamInterceptor...)
+}
+
+// initMetrics registers the server metrics on the registerer given by
+// Registerer, else on the registry of the app the server runs in, and
+// initializes the series of the registered services. Outside of an app the
+// default metrics of go-grpc-prometheus are used.
+func (s *Server) initMetrics(ctx context.Context) error {
+	reg := s.registerer
+	if reg == nil {
+		reg = registererFromContext(ctx)
+	}
+	if reg == nil {
+		grpcPrometheus.EnableHandlingTimeHistogram()
+		s.metrics = grpcPrometheus.DefaultServerMetrics
+		s.metrics.InitializeMetrics(s.Server)
+		return nil
+	}
+	metrics := grpcPrometheus.NewServerMetrics()
+	metrics.EnableHandlingTimeHistogram()
+	registered, err := tool.Register(reg, metrics)
+	if err != nil {
+		return errors.Wrap(err, "register grpc server metrics")
+	}
+	var ok bool
+	if s.metrics, ok = registered.(*grpcPrometheus.ServerMetrics); !ok {
+		return errors.Errorf("grpc server metrics are registered by a %T", registered)
+	}
+	s.metrics.InitializeMetrics(s.Server)
+	return nil
+}
+
+// registererFromContext returns the registry of the app found in ctx.
+func registererFromContext(ctx context.Context) prometheus.Registerer {
+	if info, ok := netApp.FromContext(ctx); ok {
+		if reg := info.Registry(); reg != nil {
+			return reg
+		}
+	}
+	return nil
+}
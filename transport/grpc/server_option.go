@@ -1,12 +1,22 @@
 package grpc
 
-import "google.golang.org/grpc"
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
 
 type ServerOption func(o *Server)
 
-func UnaryInterceptor(in ...grpc.UnaryServerInterceptor) ServerOption {
+// Network with server network, e.g. tcp, tcp4 or unix.
+func Network(network string) ServerOption {
 	return func(s *Server) {
-		s.unaryInterceptor = in
+		s.network = network
 	}
 }
 
@@ -16,3 +26,110 @@ func Address(addr string) ServerOption {
 		s.address = addr
 	}
 }
+
+// Listener serve on lis instead of listening on network and address.
+func Listener(lis net.Listener) ServerOption {
+	return func(s *Server) {
+		s.listener = lis
+	}
+}
+
+// Timeout with server timeout, it is the deadline of every unary request
+// unless the client sends a shorter one. Zero disables it. The streams are
+// not bounded by it, see MethodTimeout.
+func Timeout(timeout time.Duration) ServerOption {
+	return func(s *Server) {
+		s.timeout = timeout
+	}
+}
+
+// MethodTimeout with timeouts by full method name, e.g.
+// /grpc.health.v1.Health/Check, they override the server timeout. Zero
+// disables the deadline of a method. A stream is only bounded when it is
+// named here.
+func MethodTimeout(timeouts map[string]time.Duration) ServerOption {
+	return func(s *Server) {
+		if s.methodTimeouts == nil {
+			s.methodTimeouts = make(map[string]time.Duration, len(timeouts))
+		}
+		for method, timeout := range timeouts {
+			s.methodTimeouts[method] = timeout
+		}
+	}
+}
+
+// Tracing with otelgrpc server interceptors.
+func Tracing(tracing bool) ServerOption {
+	return func(s *Server) {
+		s.tracing = tracing
+	}
+}
+
+// TracerProvider trace the calls with tp instead of the tracer provider of
+// the app, or the global one outside of an app.
+func TracerProvider(tp trace.TracerProvider) ServerOption {
+	return func(s *Server) {
+		s.tracerProvider = tp
+	}
+}
+
+// Propagator extract the trace context of the calls with p instead of the
+// propagator of the app, or the global one outside of an app.
+func Propagator(p propagation.TextMapPropagator) ServerOption {
+	return func(s *Server) {
+		s.propagator = p
+	}
+}
+
+// Prometheus with go-grpc-prometheus server interceptors, the handling time
+// histogram included. The series of the registered services are initialized
+// when the server starts.
+func Prometheus(prometheus bool) ServerOption {
+	return func(s *Server) {
+		s.prometheus = prometheus
+	}
+}
+
+// Registerer register the server metrics on reg instead of the registry of
+// the app, or prometheus.DefaultRegisterer outside of an app.
+func Registerer(reg prometheus.Registerer) ServerOption {
+	return func(s *Server) {
+		s.registerer = reg
+	}
+}
+
+// Recovery with panic recovery interceptors.
+func Recovery(recovery bool) ServerOption {
+	return func(s *Server) {
+		s.recovery = recovery
+	}
+}
+
+// TLSConfig with TLS config, see tool.TLSConfig to load it from files or
+// PEM bytes and to require client certificates.
+func TLSConfig(c *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.tlsConf = c
+	}
+}
+
+// UnaryInterceptor returns a ServerOption that appends the server interceptors for unary RPCs.
+func UnaryInterceptor(in ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(s *Server) {
+		s.unaryInterceptor = append(s.unaryInterceptor, in...)
+	}
+}
+
+// StreamInterceptor returns a ServerOption that appends the server interceptors for streaming RPCs.
+func StreamInterceptor(in ...grpc.StreamServerInterceptor) ServerOption {
+	return func(s *Server) {
+		s.streamInterceptor = append(s.streamInterceptor, in...)
+	}
+}
+
+// Options with grpc options.
+func Options(opts ...grpc.ServerOption) ServerOption {
+	return func(s *Server) {
+		s.grpcOpts = append(s.grpcOpts, opts...)
+	}
+}
@@ -3,27 +3,27 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
 
 	grpcRecovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
-	"github.com/weiqiangxu/common-config/logger"
-	"google.golang.org/grpc/metadata"
+	netApp "github.com/weiqiangxu/net"
+	"google.golang.org/grpc"
 )
 
-// RecoveryDecorator decorator a recovery func to server
+// RecoveryDecorator decorator a recovery func to server, it is put in front
+// of the other interceptors so that their panics are recovered too. The
+// panics are logged with the method and the trace_id and span_id of the
+// call.
 func (s *Server) RecoveryDecorator() {
 	if s.recovery {
 		grpcRecoveryOpts := []grpcRecovery.Option{
 			grpcRecovery.WithRecoveryHandlerContext(func(ctx context.Context, p interface{}) (err error) {
-				md, ok := metadata.FromIncomingContext(ctx)
-				if ok {
-					logger.Errorf("gRPC metadata: %v panic info: %v", md, p)
-				} else {
-					logger.Error(p)
-				}
+				method, _ := grpc.Method(ctx)
+				netApp.Logger(ctx).Errorw("[gRPC] recovered from panic", "method", method, "panic", p, "stack", string(debug.Stack()))
 				return fmt.Errorf("context panic triggered: %v", p)
 			}),
 		}
-		s.unaryInterceptor = append(s.unaryInterceptor, grpcRecovery.UnaryServerInterceptor(grpcRecoveryOpts...))
-		s.streamInterceptor = append(s.streamInterceptor, grpcRecovery.StreamServerInterceptor(grpcRecoveryOpts...))
+		s.unaryInterceptor = append([]grpc.UnaryServerInterceptor{grpcRecovery.UnaryServerInterceptor(grpcRecoveryOpts...)}, s.unaryInterceptor...)
+		s.streamInterceptor = append([]grpc.StreamServerInterceptor{grpcRecovery.StreamServerInterceptor(grpcRecoveryOpts...)}, s.streamInterceptor...)
 	}
 }
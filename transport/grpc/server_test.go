@@ -1 +1,531 @@
 package grpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	netApp "github.com/weiqiangxu/net"
+	"github.com/weiqiangxu/net/tool"
+	"github.com/weiqiangxu/net/tool/logtest"
+	"github.com/weiqiangxu/net/tool/tlstest"
+	"go.opentelemetry.io/otel/propagation"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_Endpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{
+			name:    "ephemeral port",
+			address: "127.0.0.1:0",
+		},
+		{
+			name:    "invalid address",
+			address: "127.0.0.1:-1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(Address(tt.address))
+			endpoint, err := srv.Endpoint()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Endpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if endpoint.Scheme != SchemeOfGrpc || endpoint.Port() == "0" {
+				t.Fatalf("Endpoint() = %s, want a grpc endpoint with the bound port", endpoint)
+			}
+			go func() {
+				_ = srv.Start(context.Background())
+			}()
+			<-srv.Ready()
+			conn, err := net.DialTimeout("tcp", endpoint.Host, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = conn.Close()
+			if err := srv.Stop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestServer_Options(t *testing.T) {
+	var calls []string
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+	panics := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		panic("interceptor panic")
+	}
+	sock := filepath.Join(t.TempDir(), "grpc.sock")
+	tests := []struct {
+		name      string
+		opts      []ServerOption
+		wantCalls []string
+		wantCode  codes.Code
+	}{
+		{
+			name:      "unary interceptors are appended",
+			opts:      []ServerOption{UnaryInterceptor(record("first")), UnaryInterceptor(record("second"))},
+			wantCalls: []string{"first", "second"},
+			wantCode:  codes.OK,
+		},
+		{
+			name:     "recovery catches interceptor panics",
+			opts:     []ServerOption{Recovery(true), Tracing(true), UnaryInterceptor(panics)},
+			wantCode: codes.Unknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			opts := append([]ServerOption{Network("unix"), Address(sock), Timeout(time.Second)}, tt.opts...)
+			srv := NewServer(opts...)
+			go func() {
+				_ = srv.Start(context.Background())
+			}()
+			<-srv.Ready()
+			defer func() {
+				_ = srv.Stop(context.Background())
+			}()
+			endpoint, _ := srv.Endpoint()
+			conn, err := Dial(context.Background(), WithEndpoint(endpoint.String()), WithInSecure(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("Check() code = %s, want %s", code, tt.wantCode)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("interceptor calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestServer_Timeout(t *testing.T) {
+	const method = "/grpc.health.v1.Health/Check"
+	// wait blocks until the deadline like a slow handler
+	wait := func(deadlines chan<- time.Duration) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			d, _ := ctx.Deadline()
+			deadlines <- time.Until(d)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+	}
+	tests := []struct {
+		name          string
+		opts          []ServerOption
+		clientTimeout time.Duration
+		maxDeadline   time.Duration
+	}{
+		{
+			name:        "server timeout",
+			opts:        []ServerOption{Timeout(50 * time.Millisecond)},
+			maxDeadline: 50 * time.Millisecond,
+		},
+		{
+			name:        "method timeout overrides server timeout",
+			opts:        []ServerOption{Timeout(time.Minute), MethodTimeout(map[string]time.Duration{method: 50 * time.Millisecond})},
+			maxDeadline: 50 * time.Millisecond,
+		},
+		{
+			name:          "shorter client deadline is kept",
+			opts:          []ServerOption{Timeout(time.Minute)},
+			clientTimeout: 50 * time.Millisecond,
+			maxDeadline:   50 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadlines := make(chan time.Duration, 1)
+			opts := append([]ServerOption{Address("127.0.0.1:0"), UnaryInterceptor(wait(deadlines))}, tt.opts...)
+			srv := NewServer(opts...)
+			go func() {
+				_ = srv.Start(context.Background())
+			}()
+			<-srv.Ready()
+			defer func() {
+				_ = srv.Stop(context.Background())
+			}()
+			endpoint, _ := srv.Endpoint()
+			conn, err := Dial(context.Background(), WithEndpoint(endpoint.Host), WithInSecure(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			ctx := context.Background()
+			if tt.clientTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.clientTimeout)
+				defer cancel()
+			}
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if code := status.Code(err); code != codes.DeadlineExceeded {
+				t.Errorf("Check() code = %s, want %s", code, codes.DeadlineExceeded)
+			}
+			if deadline := <-deadlines; deadline <= 0 || deadline > tt.maxDeadline {
+				t.Errorf("handler deadline = %s, want at most %s", deadline, tt.maxDeadline)
+			}
+		})
+	}
+}
+
+func TestServer_StreamTimeout(t *testing.T) {
+	const watch = "/grpc.health.v1.Health/Watch"
+	tests := []struct {
+		name      string
+		opts      []ServerOption
+		wantAlive bool
+	}{
+		{
+			name:      "server timeout does not bound streams",
+			opts:      []ServerOption{Timeout(50 * time.Millisecond)},
+			wantAlive: true,
+		},
+		{
+			name: "method timeout bounds the stream",
+			opts: []ServerOption{Timeout(time.Minute), MethodTimeout(map[string]time.Duration{watch: 50 * time.Millisecond})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(append([]ServerOption{Address("127.0.0.1:0")}, tt.opts...)...)
+			go func() {
+				_ = srv.Start(context.Background())
+			}()
+			<-srv.Ready()
+			defer func() {
+				_ = srv.Stop(context.Background())
+			}()
+			endpoint, _ := srv.Endpoint()
+			conn, err := Dial(context.Background(), WithEndpoint(endpoint.Host), WithInSecure(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			stream, err := grpc_health_v1.NewHealthClient(conn).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: HealthcheckService})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := stream.Recv(); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(150 * time.Millisecond)
+			srv.health.SetServingStatus(HealthcheckService, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+			resp, err := stream.Recv()
+			if alive := err == nil; alive != tt.wantAlive {
+				t.Fatalf("stream alive = %v (err=%v), want %v", alive, err, tt.wantAlive)
+			}
+			if tt.wantAlive && resp.Status != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+				t.Errorf("watched status = %s, want %s", resp.Status, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+			}
+		})
+	}
+}
+
+func TestServer_RecoveryLog(t *testing.T) {
+	rec := &logtest.Recorder{}
+	netApp.SetLogWriter(rec)
+	defer netApp.SetLogWriter(nil)
+	srv := NewServer(
+		Address("127.0.0.1:0"),
+		Recovery(true),
+		Tracing(true),
+		TracerProvider(sdkTrace.NewTracerProvider()),
+		Propagator(propagation.TraceContext{}),
+		UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			panic("boom")
+		}),
+	)
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+	conn, err := Dial(context.Background(), WithEndpoint(endpoint.Host), WithInSecure(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	if _, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}); status.Code(err) != codes.Unknown {
+		t.Fatalf("Check() err = %v, want the recovered panic", err)
+	}
+	line, ok := rec.Wait("[gRPC] recovered from panic", time.Second)
+	if !ok {
+		t.Fatal("the panic is not logged")
+	}
+	want := map[string]interface{}{
+		"method":   "/grpc.health.v1.Health/Check",
+		"panic":    "boom",
+		"trace_id": traceID,
+	}
+	for k, v := range want {
+		if got := line.Fields[k]; got != v {
+			t.Errorf("%s = %v, want %v", k, got, v)
+		}
+	}
+	if got, _ := line.Fields["span_id"].(string); got == "" || got == "00f067aa0ba902b7" {
+		t.Errorf("span_id = %q, want the span of the server", got)
+	}
+}
+
+func TestServer_TLS(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	serverCert, serverKey := ca.Issue(t, "127.0.0.1")
+	clientCert, clientKey := ca.Issue(t, "client")
+	serverConf, err := tool.TLSConfig{CertPEM: serverCert, KeyPEM: serverKey, CAPEM: ca.CertPEM, ClientAuth: true}.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		conf     tool.TLSConfig
+		wantCode codes.Code
+	}{
+		{
+			name:     "mutual tls",
+			conf:     tool.TLSConfig{CertPEM: clientCert, KeyPEM: clientKey, CAPEM: ca.CertPEM},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "client without certificate",
+			conf:     tool.TLSConfig{CAPEM: ca.CertPEM},
+			wantCode: codes.Unavailable,
+		},
+	}
+	srv := NewServer(Address("127.0.0.1:0"), TLSConfig(serverConf))
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+	if endpoint.Query().Get("isSecure") != "true" {
+		t.Errorf("Endpoint() = %s, want isSecure=true", endpoint)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConf, err := tt.conf.Client()
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := Dial(context.Background(), WithEndpoint(endpoint.Host), WithTLSConfig(clientConf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("Check() code = %s, want %s, err=%v", code, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+// gatherValue returns the counter value, or the sample count of histograms,
+// of the metric family name with the labels.
+func gatherValue(t *testing.T, reg prometheus.Gatherer, name string, labels map[string]string) (float64, bool) {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			got := make(map[string]string, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				got[l.GetName()] = l.GetValue()
+			}
+			if !reflect.DeepEqual(got, labels) {
+				continue
+			}
+			if m.GetHistogram() != nil {
+				return float64(m.GetHistogram().GetSampleCount()), true
+			}
+			return m.GetCounter().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+func TestServer_Prometheus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	srv := NewServer(Address("127.0.0.1:0"), Prometheus(true), Registerer(reg))
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	check := map[string]string{"grpc_service": HealthcheckService, "grpc_method": "Check", "grpc_type": "unary"}
+	if got, ok := gatherValue(t, reg, "grpc_server_started_total", check); !ok || got != 0 {
+		t.Fatalf("started before any call = %v %v, want an initialized 0", got, ok)
+	}
+
+	endpoint, _ := srv.Endpoint()
+	conn, err := Dial(context.Background(), WithEndpoint(endpoint.Host), WithInSecure(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	handled := map[string]string{"grpc_code": codes.OK.String()}
+	for k, v := range check {
+		handled[k] = v
+	}
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{name: "grpc_server_started_total", labels: check, want: 1},
+		{name: "grpc_server_handled_total", labels: handled, want: 1},
+		{name: "grpc_server_handling_seconds", labels: check, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := gatherValue(t, reg, tt.name, tt.labels); !ok || got != tt.want {
+				t.Errorf("%s%v = %v %v, want %v", tt.name, tt.labels, got, ok, tt.want)
+			}
+		})
+	}
+
+	t.Run("shared registerer", func(t *testing.T) {
+		other := NewServer(Prometheus(true), Registerer(reg))
+		if err := other.initMetrics(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if other.metrics != srv.metrics {
+			t.Error("the second server on the registerer registered its own metrics")
+		}
+	})
+}
+
+func TestServer_AppRegistry(t *testing.T) {
+	// every app has its own registry, the servers and clients of the apps
+	// in one process do not collide
+	for _, name := range []string{"foo", "bar"} {
+		t.Run(name, func(t *testing.T) {
+			app := netApp.New(netApp.Name(name))
+			ctx := netApp.NewContext(context.Background(), app)
+			srv := NewServer(Address("127.0.0.1:0"), Prometheus(true))
+			go func() {
+				_ = srv.Start(ctx)
+			}()
+			<-srv.Ready()
+			defer func() {
+				_ = srv.Stop(context.Background())
+			}()
+			endpoint, _ := srv.Endpoint()
+			conn, err := Dial(ctx, WithEndpoint(endpoint.Host), WithInSecure(true), WithPrometheus(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+				t.Fatal(err)
+			}
+			labels := map[string]string{"grpc_service": HealthcheckService, "grpc_method": "Check", "grpc_type": "unary"}
+			if got, ok := gatherValue(t, app.Registry(), "grpc_server_started_total", labels); !ok || got != 1 {
+				t.Errorf("app registry grpc_server_started_total = %v %v, want 1", got, ok)
+			}
+			labels["service"] = name
+			if got, ok := gatherValue(t, app.Registry(), "grpc_client_started_total", labels); !ok || got != 1 {
+				t.Errorf("app registry grpc_client_started_total = %v %v, want 1", got, ok)
+			}
+		})
+	}
+}
+
+func TestServer_AppTracerProvider(t *testing.T) {
+	// every app traces with its own provider, the apps running in parallel
+	// do not see the spans of each other
+	for _, name := range []string{"foo", "bar"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			exp := tracetest.NewInMemoryExporter()
+			srv := NewServer(Address("127.0.0.1:0"), Tracing(true))
+			var app *netApp.App
+			call := netApp.AfterStart(func(ctx context.Context) error {
+				defer app.Stop()
+				endpoint, _ := srv.Endpoint()
+				conn, err := Dial(ctx, WithEndpoint(endpoint.Host), WithInSecure(true), WithTracing(true))
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				return listServices(ctx, conn)
+			})
+			app = netApp.New(netApp.Name(name), netApp.Server(srv), netApp.ExportMemory(exp), call)
+			if err := app.Run(); err != nil {
+				t.Fatal(err)
+			}
+			spans := exp.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("recorded spans = %d, want the client and server spans", len(spans))
+			}
+			kinds := make(map[trace.SpanKind]tracetest.SpanStub)
+			for _, span := range spans {
+				kinds[span.SpanKind] = span
+				if got := resourceServiceName(span); got != name {
+					t.Errorf("span %s service name = %q, want %q", span.Name, got, name)
+				}
+			}
+			client, server := kinds[trace.SpanKindClient], kinds[trace.SpanKindServer]
+			if server.Parent.SpanID() != client.SpanContext.SpanID() {
+				t.Errorf("server span parent = %s, want the client span %s", server.Parent.SpanID(), client.SpanContext.SpanID())
+			}
+		})
+	}
+}
+
+func resourceServiceName(span tracetest.SpanStub) string {
+	if span.Resource == nil {
+		return ""
+	}
+	v, _ := span.Resource.Set().Value(semConv.ServiceNameKey)
+	return v.AsString()
+}
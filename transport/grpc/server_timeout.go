@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	grpcMiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TimeoutDecorator decorator a deadline func to server, every unary request
+// is bounded by the method timeout or the server timeout unless the client
+// asked for a shorter deadline. The streams are long lived, e.g. the health
+// Watch, only the ones named by MethodTimeout are bounded.
+func (s *Server) TimeoutDecorator() {
+	s.unaryInterceptor = append([]grpc.UnaryServerInterceptor{s.unaryTimeoutInterceptor}, s.unaryInterceptor...)
+	s.streamInterceptor = append([]grpc.StreamServerInterceptor{s.streamTimeoutInterceptor}, s.streamInterceptor...)
+}
+
+func (s *Server) unaryTimeoutInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	timeout, ok := s.methodTimeouts[info.FullMethod]
+	if !ok {
+		timeout = s.timeout
+	}
+	ctx, cancel := withDeadline(ctx, timeout)
+	defer cancel()
+	reply, err := handler(ctx, req)
+	return reply, deadlineError(ctx, err)
+}
+
+func (s *Server) streamTimeoutInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	timeout, ok := s.methodTimeouts[info.FullMethod]
+	if !ok {
+		return handler(srv, ss)
+	}
+	ctx, cancel := withDeadline(ss.Context(), timeout)
+	defer cancel()
+	wrapped := grpcMiddleware.WrapServerStream(ss)
+	wrapped.WrappedContext = ctx
+	return deadlineError(ctx, handler(srv, wrapped))
+}
+
+// withDeadline bounds ctx by timeout, an earlier deadline of the client is
+// kept as it is.
+func withDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// deadlineError turns the plain errors of a handler which ran out of time
+// into codes.DeadlineExceeded, the status errors are returned as they are.
+func deadlineError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		return err
+	}
+	return status.Error(codes.DeadlineExceeded, err.Error())
+}
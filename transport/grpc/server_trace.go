@@ -1,11 +1,52 @@
 package grpc
 
-import "go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+import (
+	"context"
 
-// TraceDecorator decorator a trace func to server
+	netApp "github.com/weiqiangxu/net"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+)
+
+// TraceDecorator decorator a trace func to server, it is put in front of the
+// other interceptors so that their work is part of the span. The tracer
+// provider and propagator of the app are taken when the server starts, see
+// initTracing.
 func (s *Server) TraceDecorator() {
-	if s.tracing {
-		s.unaryInterceptor = append(s.unaryInterceptor, otelgrpc.UnaryServerInterceptor())
-		s.streamInterceptor = append(s.streamInterceptor, otelgrpc.StreamServerInterceptor())
+	if !s.tracing {
+		return
+	}
+	s.setTraceInterceptors()
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return s.traceUnary(ctx, req, info, handler)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return s.traceStream(srv, ss, info, handler)
+	}
+	s.unaryInterceptor = append([]grpc.UnaryServerInterceptor{unary}, s.unaryInterceptor...)
+	s.streamInterceptor = append([]grpc.StreamServerInterceptor{stream}, s.streamInterceptor...)
+}
+
+// initTracing traces with the tracer provider and propagator of the app the
+// server runs in, unless they are given with TracerProvider and Propagator.
+func (s *Server) initTracing(ctx context.Context) {
+	info, ok := netApp.FromContext(ctx)
+	if !ok {
+		return
+	}
+	if s.tracerProvider == nil {
+		s.tracerProvider = info.TracerProvider()
+	}
+	if s.propagator == nil {
+		s.propagator = info.Propagator()
 	}
+	s.setTraceInterceptors()
+}
+
+// setTraceInterceptors builds the otelgrpc interceptors, the globals are used
+// for a nil tracer provider or propagator.
+func (s *Server) setTraceInterceptors() {
+	opts := traceOptions(s.tracerProvider, s.propagator)
+	s.traceUnary = otelgrpc.UnaryServerInterceptor(opts...)
+	s.traceStream = otelgrpc.StreamServerInterceptor(opts...)
 }
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// NewGatewayMux create a grpc-gateway mux for WithGateway. Register the
+// generated handlers on it, either with RegisterXxxHandlerServer to call a
+// local service in process, or with RegisterXxxHandler and a connection of
+// grpc.Dial to go through the interceptors of the gRPC server.
+//
+// Besides the Grpc-Metadata-* headers forwarded by the default matcher, the
+// trace context of the request is injected into the gRPC metadata so that
+// the spans of the gRPC server join the trace of the HTTP request.
+func NewGatewayMux(opts ...runtime.ServeMuxOption) *runtime.ServeMux {
+	opts = append([]runtime.ServeMuxOption{runtime.WithMetadata(traceMetadata)}, opts...)
+	return runtime.NewServeMux(opts...)
+}
+
+type propagatorKey struct{}
+
+// traceMetadata returns the trace context of ctx as gRPC metadata, the one
+// of the request headers is used when no span is recording. It is propagated
+// with the propagator of the server, or the global one outside of a server.
+func traceMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	propagator, ok := ctx.Value(propagatorKey{}).(propagation.TextMapPropagator)
+	if !ok {
+		propagator = otel.GetTextMapPropagator()
+	}
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = propagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	}
+	header := http.Header{}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	md := make(metadata.MD, len(header))
+	for k, v := range header {
+		md[strings.ToLower(k)] = v
+	}
+	return md
+}
+
+// mountGateway routes the requests under prefix to the gateway, with an
+// empty prefix it gets every request no route matched.
+func (s *Server) mountGateway() {
+	if s.gateway == nil {
+		return
+	}
+	handler := func(c *gin.Context) {
+		// gin presets 404 for the no route handlers, the gateway writes
+		// the successful responses without an explicit status
+		c.Status(http.StatusOK)
+		r := c.Request
+		if s.propagator != nil {
+			r = r.WithContext(context.WithValue(r.Context(), propagatorKey{}, s.propagator))
+		}
+		s.gateway.ServeHTTP(c.Writer, r)
+	}
+	prefix := strings.TrimSuffix(s.gatewayPrefix, "/")
+	if prefix == "" {
+		s.gin.NoRoute(handler)
+		return
+	}
+	s.gin.Any(prefix+"/*path", handler)
+}
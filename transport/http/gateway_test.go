@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	netGrpc "github.com/weiqiangxu/net/transport/grpc"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestWithGateway(t *testing.T) {
+	incoming := make(chan metadata.MD, 1)
+	grpcSrv := netGrpc.NewServer(
+		netGrpc.Address("127.0.0.1:0"),
+		netGrpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			select {
+			case incoming <- md:
+			default:
+			}
+			return handler(ctx, req)
+		}),
+	)
+	go func() {
+		_ = grpcSrv.Start(context.Background())
+	}()
+	<-grpcSrv.Ready()
+	defer func() {
+		_ = grpcSrv.Stop(context.Background())
+	}()
+	grpcEndpoint, _ := grpcSrv.Endpoint()
+	conn, err := netGrpc.Dial(context.Background(), netGrpc.WithEndpoint(grpcEndpoint.Host), netGrpc.WithInSecure(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// stands for a generated RegisterXxxHandler
+	mux := NewGatewayMux()
+	err = mux.HandlePath(http.MethodGet, "/v1/health", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, "/grpc.health.v1.Health/Check")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(resp.Status.String()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name       string
+		prefix     string
+		propagator propagation.TextMapPropagator
+		header     string
+		value      string
+	}{
+		{
+			name:       "not matched routes",
+			propagator: propagation.TraceContext{},
+			header:     "traceparent",
+			value:      traceparent,
+		},
+		{
+			name:       "prefix",
+			prefix:     "/v1",
+			propagator: propagation.TraceContext{},
+			header:     "traceparent",
+			value:      traceparent,
+		},
+		{
+			name:       "server propagator",
+			propagator: b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)),
+			header:     "b3",
+			value:      "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(WithAddress("127.0.0.1:0"), WithGateway(tt.prefix, mux), WithPropagator(tt.propagator))
+			go func() {
+				_ = srv.Start(context.Background())
+			}()
+			<-srv.Ready()
+			defer func() {
+				_ = srv.Stop(context.Background())
+			}()
+			endpoint, _ := srv.Endpoint()
+			req, _ := http.NewRequest(http.MethodGet, endpoint.String()+"/v1/health", nil)
+			req.Header.Set(tt.header, tt.value)
+			req.Header.Set("Grpc-Metadata-Tenant", "acme")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET /v1/health = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			md := <-incoming
+			if got := md.Get(tt.header); len(got) != 1 || got[0] != tt.value {
+				t.Errorf("%s metadata = %v, want %s", tt.header, got, tt.value)
+			}
+			if got := md.Get("tenant"); len(got) != 1 || got[0] != "acme" {
+				t.Errorf("tenant metadata = %v, want acme", got)
+			}
+		})
+	}
+}
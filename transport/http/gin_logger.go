@@ -9,7 +9,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/weiqiangxu/common-config/logger"
+	netApp "github.com/weiqiangxu/net"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,7 +20,8 @@ type GinLoggerConfig struct {
 	SkipPaths  []string
 }
 
-// GinZapWithConfig returns a gin.HandlerFunc using configs
+// GinZapWithConfig returns a gin.HandlerFunc using configs, the lines carry
+// the trace_id and span_id of the request.
 func GinZapWithConfig(conf *GinLoggerConfig) gin.HandlerFunc {
 	skipPaths := make(map[string]bool, len(conf.SkipPaths))
 	for _, path := range conf.SkipPaths {
@@ -48,7 +49,7 @@ func GinZapWithConfig(conf *GinLoggerConfig) gin.HandlerFunc {
 				"latency", latency,
 				"time", end.Format(conf.TimeFormat),
 			}
-			logger.Infow(path, messages...)
+			netApp.Logger(c.Request.Context()).Infow(path, messages...)
 		}
 	}
 }
@@ -74,17 +75,18 @@ func RecoveryWithZap(stack bool) gin.HandlerFunc {
 						}
 					}
 				}
+				log := netApp.Logger(c.Request.Context())
 				httpRequest, _ := httputil.DumpRequest(c.Request, false)
 				if brokenPipe {
 					message := []interface{}{
 						"error", err,
 						"request", string(httpRequest),
 					}
-					logger.Errorw(c.Request.URL.Path, message...)
+					log.Errorw(c.Request.URL.Path, message...)
 					// If the connection is dead, we can't write a status to it.
 					e := c.Error(err.(error))
 					if e != nil {
-						logger.Error(e.Error())
+						log.Error(e.Error())
 					}
 					c.Abort()
 					return
@@ -96,14 +98,14 @@ func RecoveryWithZap(stack bool) gin.HandlerFunc {
 						"request", string(httpRequest),
 						"stack", debug.Stack(),
 					}
-					logger.Errorw("[Recovery from panic]", message...)
+					log.Errorw("[Recovery from panic]", message...)
 				} else {
 					message := []interface{}{
 						"time", time.Now(),
 						"error", err,
 						"request", string(httpRequest),
 					}
-					logger.Errorw("[Recovery from panic]", message...)
+					log.Errorw("[Recovery from panic]", message...)
 				}
 				c.AbortWithStatus(http.StatusInternalServerError)
 			}
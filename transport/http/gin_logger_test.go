@@ -0,0 +1,106 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	netApp "github.com/weiqiangxu/net"
+	"github.com/weiqiangxu/net/tool/logtest"
+	netGrpc "github.com/weiqiangxu/net/transport/grpc"
+	"go.opentelemetry.io/otel/propagation"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestServer_LogTraceFields(t *testing.T) {
+	rec := &logtest.Recorder{}
+	netApp.SetLogWriter(rec)
+	defer netApp.SetLogWriter(nil)
+	srv := NewServer(
+		WithAddress("127.0.0.1:0"),
+		WithTracing(true),
+		WithTracerProvider(sdkTrace.NewTracerProvider()),
+		WithPropagator(propagation.TraceContext{}),
+		WithGRPCWeb(netGrpc.NewServer()),
+	)
+	srv.Server().GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	srv.Server().GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		header    map[string]string
+		body      []byte
+		wantMsg   string
+		wantLevel string
+	}{
+		{
+			name:      "request",
+			method:    http.MethodGet,
+			path:      "/ping",
+			wantMsg:   "/ping",
+			wantLevel: "info",
+		},
+		{
+			name:      "panic",
+			method:    http.MethodGet,
+			path:      "/panic",
+			wantMsg:   "[Recovery from panic]",
+			wantLevel: "error",
+		},
+		{
+			name:      "grpc-web",
+			method:    http.MethodPost,
+			path:      "/grpc.health.v1.Health/Check",
+			header:    map[string]string{"Content-Type": "application/grpc-web+proto", "X-Grpc-Web": "1"},
+			body:      grpcWebFrame(t, &grpc_health_v1.HealthCheckRequest{}),
+			wantMsg:   "/grpc.health.v1.Health/Check",
+			wantLevel: "info",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, endpoint.String()+tt.path, bytes.NewReader(tt.body))
+			req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			line, ok := rec.Wait(tt.wantMsg, time.Second)
+			if !ok {
+				t.Fatalf("no line logged with %q", tt.wantMsg)
+			}
+			if line.Level != tt.wantLevel {
+				t.Errorf("level = %s, want %s", line.Level, tt.wantLevel)
+			}
+			if got := line.Fields["trace_id"]; got != traceID {
+				t.Errorf("trace_id = %v, want %s", got, traceID)
+			}
+			if got, _ := line.Fields["span_id"].(string); got == "" || got == "00f067aa0ba902b7" {
+				t.Errorf("span_id = %q, want the span of the server", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+)
+
+// grpcWebMiddleware serves the gRPC-Web and gRPC-Web-Text requests and their
+// CORS preflights with the wrapped gRPC server, the other requests go on to
+// the routes of the engine.
+func (s *Server) grpcWebMiddleware() gin.HandlerFunc {
+	wrapped := grpcweb.WrapServer(s.grpcWeb.Server, s.grpcWebOpts...)
+	return func(c *gin.Context) {
+		if !wrapped.IsGrpcWebRequest(c.Request) && !wrapped.IsAcceptableGrpcCorsRequest(c.Request) {
+			c.Next()
+			return
+		}
+		// gin presets 404 when no route matched, the preflights are answered
+		// without an explicit status
+		c.Status(http.StatusOK)
+		wrapped.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
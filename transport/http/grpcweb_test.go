@@ -0,0 +1,167 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	netGrpc "github.com/weiqiangxu/net/transport/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcWebFrame frames msg as a gRPC-Web data frame.
+func grpcWebFrame(t *testing.T, msg proto.Message) []byte {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+	return append(frame, b...)
+}
+
+// readGrpcWebFrame reads the next frame of a gRPC-Web response body, the
+// first bit of the flag marks the trailers.
+func readGrpcWebFrame(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+func TestWithGRPCWeb(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	grpcSrv := netGrpc.NewServer(netGrpc.Timeout(timeout))
+	srv := NewServer(
+		WithAddress("127.0.0.1:0"),
+		WithGRPCWeb(grpcSrv, grpcweb.WithOriginFunc(func(origin string) bool {
+			return origin == "http://web.example.com"
+		})),
+	)
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+	req := &grpc_health_v1.HealthCheckRequest{Service: netGrpc.HealthcheckService}
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		text        bool
+	}{
+		{name: "grpc-web unary", method: "/grpc.health.v1.Health/Check", contentType: "application/grpc-web+proto"},
+		{name: "grpc-web-text unary", method: "/grpc.health.v1.Health/Check", contentType: "application/grpc-web-text", text: true},
+		{name: "grpc-web server streaming", method: "/grpc.health.v1.Health/Watch", contentType: "application/grpc-web+proto"},
+		{name: "grpc-web-text server streaming", method: "/grpc.health.v1.Health/Watch", contentType: "application/grpc-web-text", text: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := grpcWebFrame(t, req)
+			if tt.text {
+				body = []byte(base64.StdEncoding.EncodeToString(body))
+			}
+			httpReq, _ := http.NewRequest(http.MethodPost, endpoint.String()+tt.method, bytes.NewReader(body))
+			httpReq.Header.Set("Content-Type", tt.contentType)
+			httpReq.Header.Set("X-Grpc-Web", "1")
+			resp, err := http.DefaultClient.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST %s = %d, want %d", tt.method, resp.StatusCode, http.StatusOK)
+			}
+			var r io.Reader = resp.Body
+			if tt.text {
+				r = base64.NewDecoder(base64.StdEncoding, resp.Body)
+			}
+			// the watch stream stays open after the first message
+			flag, payload, err := readGrpcWebFrame(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flag != 0 {
+				t.Fatalf("first frame flag = %#x, want a data frame", flag)
+			}
+			got := &grpc_health_v1.HealthCheckResponse{}
+			if err := proto.Unmarshal(payload, got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+				t.Errorf("status = %s, want %s", got.Status, grpc_health_v1.HealthCheckResponse_SERVING)
+			}
+			if strings.HasSuffix(tt.method, "/Watch") {
+				// the watch stream outlives the server timeout, it is only
+				// closed with the request
+				next := make(chan error, 1)
+				go func() {
+					_, _, err := readGrpcWebFrame(r)
+					next <- err
+				}()
+				select {
+				case err := <-next:
+					t.Fatalf("watch stream ended within %s, err=%v", 3*timeout, err)
+				case <-time.After(3 * timeout):
+				}
+				return
+			}
+			flag, payload, err = readGrpcWebFrame(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flag&0x80 == 0 || !strings.Contains(strings.ToLower(string(payload)), "grpc-status: 0") {
+				t.Errorf("trailer frame = %#x %q, want grpc-status 0", flag, payload)
+			}
+		})
+	}
+
+	t.Run("cors preflight", func(t *testing.T) {
+		for origin, allowed := range map[string]bool{"http://web.example.com": true, "http://evil.example.com": false} {
+			httpReq, _ := http.NewRequest(http.MethodOptions, endpoint.String()+"/grpc.health.v1.Health/Check", nil)
+			httpReq.Header.Set("Origin", origin)
+			httpReq.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			httpReq.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web,x-user-agent")
+			resp, err := http.DefaultClient.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			got := resp.Header.Get("Access-Control-Allow-Origin")
+			if allowed && got != origin {
+				t.Errorf("%s Access-Control-Allow-Origin = %q, want %q", origin, got, origin)
+			}
+			if !allowed && got != "" {
+				t.Errorf("%s Access-Control-Allow-Origin = %q, want none", origin, got)
+			}
+		}
+	})
+
+	t.Run("other requests", func(t *testing.T) {
+		resp, err := http.Get(endpoint.String() + "/healthC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /healthC = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+}
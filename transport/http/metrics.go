@@ -0,0 +1,123 @@
+package http
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weiqiangxu/net/tool"
+)
+
+// UnmatchedRoute is the route label of the requests no route matched, the
+// raw paths would make the series unbounded.
+const UnmatchedRoute = "unmatched"
+
+var (
+	DefaultDurationBuckets = prometheus.DefBuckets
+	DefaultSizeBuckets     = prometheus.ExponentialBuckets(100, 10, 7)
+)
+
+type MetricsConfig struct {
+	// Registerer defaults to prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// DurationBuckets in seconds, default DefaultDurationBuckets. They are
+	// ignored when the histogram is already registered on the Registerer.
+	DurationBuckets []float64
+	// SizeBuckets in bytes, default DefaultSizeBuckets. They are ignored when
+	// the histograms are already registered on the Registerer.
+	SizeBuckets []float64
+}
+
+// MetricsWithConfig returns a gin.HandlerFunc recording the rate, errors and
+// duration of the requests by route template, method and status, the
+// requests in flight and the sizes of the requests and responses. The
+// collectors registered by another server on the same registerer are shared,
+// together with their buckets.
+func MetricsWithConfig(conf *MetricsConfig) (gin.HandlerFunc, error) {
+	reg := conf.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	durationBuckets := conf.DurationBuckets
+	if len(durationBuckets) == 0 {
+		durationBuckets = DefaultDurationBuckets
+	}
+	sizeBuckets := conf.SizeBuckets
+	if len(sizeBuckets) == 0 {
+		sizeBuckets = DefaultSizeBuckets
+	}
+	labels := []string{"method", "route", "status"}
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "Total number of HTTP requests handled by the server.",
+	}, labels)
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Duration of the HTTP requests handled by the server.",
+		Buckets: durationBuckets,
+	}, labels)
+	inFlight := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_server_requests_in_flight",
+		Help: "Number of HTTP requests being handled by the server.",
+	}, []string{"method", "route"})
+	requestSize := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_size_bytes",
+		Help:    "Size of the bodies of the HTTP requests.",
+		Buckets: sizeBuckets,
+	}, labels)
+	responseSize := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_response_size_bytes",
+		Help:    "Size of the bodies of the HTTP responses.",
+		Buckets: sizeBuckets,
+	}, labels)
+	collectors := []prometheus.Collector{requests, duration, inFlight, requestSize, responseSize}
+	for i, c := range collectors {
+		registered, err := tool.Register(reg, c)
+		if err != nil {
+			return nil, errors.Wrap(err, "register http metrics")
+		}
+		collectors[i] = registered
+	}
+	var ok [5]bool
+	requests, ok[0] = collectors[0].(*prometheus.CounterVec)
+	duration, ok[1] = collectors[1].(*prometheus.HistogramVec)
+	inFlight, ok[2] = collectors[2].(*prometheus.GaugeVec)
+	requestSize, ok[3] = collectors[3].(*prometheus.HistogramVec)
+	responseSize, ok[4] = collectors[4].(*prometheus.HistogramVec)
+	for i := range ok {
+		if !ok[i] {
+			return nil, errors.Errorf("http metrics are registered by a %T", collectors[i])
+		}
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		method := c.Request.Method
+		// the route is matched before the handlers run
+		route := c.FullPath()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		gauge := inFlight.WithLabelValues(method, route)
+		gauge.Inc()
+		defer gauge.Dec()
+
+		c.Next()
+
+		status := strconv.Itoa(c.Writer.Status())
+		requests.WithLabelValues(method, route, status).Inc()
+		duration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+		reqSize := c.Request.ContentLength
+		if reqSize < 0 {
+			reqSize = 0
+		}
+		requestSize.WithLabelValues(method, route, status).Observe(float64(reqSize))
+		respSize := c.Writer.Size()
+		if respSize < 0 {
+			respSize = 0
+		}
+		responseSize.WithLabelValues(method, route, status).Observe(float64(respSize))
+	}, nil
+}
@@ -0,0 +1,190 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	netApp "github.com/weiqiangxu/net"
+)
+
+// gatherMetric returns the sample of the metric family name whose label
+// values are labels in order.
+func gatherMetric(reg prometheus.Gatherer, name string, labels []string) (*dto.Metric, error) {
+	families, err := reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			values := make([]string, 0, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				values = append(values, l.GetValue())
+			}
+			if reflect.DeepEqual(values, labels) {
+				return m, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("no %s sample with labels %v", name, labels)
+}
+
+func TestWithPrometheus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	srv := NewServer(
+		WithAddress("127.0.0.1:0"),
+		WithPrometheus(true),
+		WithRegisterer(reg),
+		WithDurationBuckets(0.5, 1),
+	)
+	srv.Server().GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+	for _, path := range []string{"/users/1", "/users/22", "/nope"} {
+		resp, err := http.Get(endpoint.String() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{name: "route template", labels: []string{http.MethodGet, "/users/:id", "200"}, want: 2},
+		{name: "unmatched", labels: []string{http.MethodGet, UnmatchedRoute, "404"}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, err := gatherMetric(reg, "http_server_requests_total", tt.labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := requests.GetCounter().GetValue(); got != tt.want {
+				t.Errorf("requests %v = %v, want %v", tt.labels, got, tt.want)
+			}
+			duration, err := gatherMetric(reg, "http_server_request_duration_seconds", tt.labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var bounds []float64
+			for _, b := range duration.GetHistogram().GetBucket() {
+				bounds = append(bounds, b.GetUpperBound())
+			}
+			if !reflect.DeepEqual(bounds, []float64{0.5, 1}) {
+				t.Errorf("duration buckets = %v, want [0.5 1]", bounds)
+			}
+			if got := duration.GetHistogram().GetSampleCount(); got != uint64(tt.want) {
+				t.Errorf("duration count %v = %v, want %v", tt.labels, got, tt.want)
+			}
+			size, err := gatherMetric(reg, "http_server_response_size_bytes", tt.labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := size.GetHistogram().GetSampleCount(); got != uint64(tt.want) {
+				t.Errorf("response size count %v = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("in flight", func(t *testing.T) {
+		if got := testutil.CollectAndCount(reg, "http_server_requests_in_flight"); got != 2 {
+			t.Errorf("in flight series = %d, want 2", got)
+		}
+		gauge, err := gatherMetric(reg, "http_server_requests_in_flight", []string{http.MethodGet, "/users/:id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := gauge.GetGauge().GetValue(); got != 0 {
+			t.Errorf("in flight = %v, want 0", got)
+		}
+	})
+
+	t.Run("metrics endpoint", func(t *testing.T) {
+		resp, err := http.Get(endpoint.String() + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(body), `http_server_requests_total{method="GET",route="/users/:id",status="200"} 2`) {
+			t.Errorf("/metrics does not serve the registry:\n%s", body)
+		}
+	})
+
+	t.Run("shared registerer", func(t *testing.T) {
+		if _, err := MetricsWithConfig(&MetricsConfig{Registerer: reg}); err != nil {
+			t.Errorf("second middleware on the registerer err = %v", err)
+		}
+	})
+
+	t.Run("registered by another type", func(t *testing.T) {
+		other := prometheus.NewRegistry()
+		other.MustRegister(prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Name: "http_server_requests_total",
+			Help: "Total number of HTTP requests handled by the server.",
+		}, []string{"method", "route", "status"}))
+		if _, err := MetricsWithConfig(&MetricsConfig{Registerer: other}); err == nil {
+			t.Error("MetricsWithConfig() error = nil, want the type of the registered collector")
+		}
+	})
+}
+
+func TestServer_AppRegistry(t *testing.T) {
+	// every app has its own registry, the servers of the apps in one process
+	// do not collide
+	for _, name := range []string{"foo", "bar"} {
+		t.Run(name, func(t *testing.T) {
+			app := netApp.New(netApp.Name(name))
+			srv := NewServer(WithAddress("127.0.0.1:0"), WithPrometheus(true))
+			go func() {
+				_ = srv.Start(netApp.NewContext(context.Background(), app))
+			}()
+			<-srv.Ready()
+			defer func() {
+				_ = srv.Stop(context.Background())
+			}()
+			endpoint, _ := srv.Endpoint()
+			resp, err := http.Get(endpoint.String() + "/healthC")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if _, err := gatherMetric(app.Registry(), "http_server_requests_total", []string{http.MethodGet, "/healthC", "200"}); err != nil {
+				t.Error(err)
+			}
+			resp, err = http.Get(endpoint.String() + "/metrics")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			for _, metric := range []string{"http_server_requests_total", "go_goroutines"} {
+				if !strings.Contains(string(body), metric) {
+					t.Errorf("/metrics does not serve %s of the app registry", metric)
+				}
+			}
+		})
+	}
+}
@@ -2,40 +2,75 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
+	netApp "github.com/weiqiangxu/net"
+	"github.com/weiqiangxu/net/tool"
 	"github.com/weiqiangxu/net/transport"
-	"github.com/weiqiangxu/user/config"
+	netGrpc "github.com/weiqiangxu/net/transport/grpc"
 
 	"github.com/weiqiangxu/common-config/logger"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/gin-gonic/gin"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
 
 	ginPprof "github.com/gin-contrib/pprof"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const (
 	DefaultHttpNetwork = "tcp"
 	DefaultHttpAddress = ":0"
+	SchemeOfHttp       = "http"
+	SchemeOfHttps      = "https"
 )
 
-var _ transport.Server = (*Server)(nil)
+var (
+	_ transport.Server     = (*Server)(nil)
+	_ transport.Readier    = (*Server)(nil)
+	_ transport.Endpointer = (*Server)(nil)
+)
 
 type Server struct {
-	gin           *gin.Engine
-	httpServer    *http.Server
-	address       string
-	network       string
-	handlersChain []gin.HandlerFunc
-	prometheus    bool
-	profile       bool
-	tracing       bool
+	gin             *gin.Engine
+	httpServer      *http.Server
+	tlsConf         *tls.Config
+	listener        net.Listener
+	once            sync.Once
+	err             error
+	address         string
+	network         string
+	endpoint        *url.URL
+	handlersChain   []gin.HandlerFunc
+	prometheus      bool
+	registerer      prometheus.Registerer
+	durationBuckets []float64
+	sizeBuckets     []float64
+	metrics         gin.HandlerFunc
+	metricsHandler  http.Handler
+	profile         bool
+	tracing         bool
+	tracerProvider  trace.TracerProvider
+	propagator      propagation.TextMapPropagator
+	h2c             bool
+	gateway         http.Handler
+	gatewayPrefix   string
+	grpcWeb         *netGrpc.Server
+	grpcWebOpts     []grpcweb.Option
+	serviceName     string
+	ready           chan struct{}
 }
 
 func NewServer(opts ...ServerOption) *Server {
@@ -45,58 +80,211 @@ func NewServer(opts ...ServerOption) *Server {
 	srv := &Server{
 		network: DefaultHttpNetwork,
 		address: DefaultHttpAddress,
+		ready:   make(chan struct{}),
 	}
 	for _, o := range opts {
 		o(srv)
 	}
 	if srv.prometheus {
-		g.GET("metrics", gin.WrapH(promhttp.Handler()))
-		prometheus.Unregister(collectors.NewGoCollector())
-		collectorOpt := collectors.ProcessCollectorOpts(prometheus.ProcessCollectorOpts{})
-		collector := collectors.NewProcessCollector(collectorOpt)
-		prometheus.Unregister(collector)
+		srv.mountMetrics(g)
 	}
 	if srv.profile {
 		ginPprof.Register(g)
 	}
 	if srv.tracing {
-		g.Use(otelgin.Middleware(config.Conf.Application.Name))
-	}
-	if len(srv.handlersChain) > 0 {
-		g.Use(srv.handlersChain...)
+		g.Use(srv.tracingMiddleware())
 	}
+	// the gRPC-Web requests are logged and recovered as well
 	g.Use(GinZapWithConfig(&GinLoggerConfig{
 		TimeFormat: time.RFC3339,
 		UTC:        false,
 		SkipPaths:  []string{"/healthC"},
 	}))
 	g.Use(RecoveryWithZap(true))
+	if srv.grpcWeb != nil {
+		g.Use(srv.grpcWebMiddleware())
+	}
+	if len(srv.handlersChain) > 0 {
+		g.Use(srv.handlersChain...)
+	}
 	g.GET("/healthC", func(c *gin.Context) {
 		c.JSON(http.StatusOK, http.StatusText(http.StatusOK))
 	})
 	srv.gin = g
+	srv.mountGateway()
+	srv.httpServer = &http.Server{
+		Addr:      srv.address,
+		Handler:   g,
+		TLSConfig: srv.tlsConf,
+	}
+	if srv.h2c && srv.tlsConf == nil {
+		h2s := &http2.Server{}
+		// lets Shutdown send GOAWAY to the hijacked HTTP/2 connections
+		if err := http2.ConfigureServer(srv.httpServer, h2s); err != nil {
+			logger.Errorf("[HTTP] configure http2 server catch err=%v", err)
+		}
+		srv.httpServer.Handler = h2c.NewHandler(g, h2s)
+	}
 	return srv
 }
 
+// mountMetrics records the requests and serves the metrics on /metrics,
+// both wait for the registerer which is resolved when the server starts.
+func (s *Server) mountMetrics(g *gin.Engine) {
+	g.Use(func(c *gin.Context) {
+		if s.metrics == nil {
+			c.Next()
+			return
+		}
+		s.metrics(c)
+	})
+	g.GET("metrics", func(c *gin.Context) {
+		if s.metricsHandler == nil {
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		s.metricsHandler.ServeHTTP(c.Writer, c.Request)
+	})
+}
+
+// initMetrics registers the request metrics on the registerer given by
+// WithRegisterer, else on the registry of the app the server runs in. The
+// default gatherer is served unless the registerer is a prometheus.Gatherer.
+func (s *Server) initMetrics(ctx context.Context) error {
+	if info, ok := netApp.FromContext(ctx); ok && s.registerer == nil {
+		if reg := info.Registry(); reg != nil {
+			s.registerer = reg
+		}
+	}
+	metrics, err := MetricsWithConfig(&MetricsConfig{
+		Registerer:      s.registerer,
+		DurationBuckets: s.durationBuckets,
+		SizeBuckets:     s.sizeBuckets,
+	})
+	if err != nil {
+		return err
+	}
+	s.metrics = metrics
+	s.metricsHandler = promhttp.Handler()
+	if gatherer, ok := s.registerer.(prometheus.Gatherer); ok {
+		s.metricsHandler = promhttp.InstrumentMetricHandler(s.registerer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	}
+	return nil
+}
+
 // Server gin
 func (s *Server) Server() *gin.Engine {
 	return s.gin
 }
 
 func (s *Server) Start(ctx context.Context) error {
-	srv := &http.Server{
-		Addr:    s.address,
-		Handler: s.gin,
+	if info, ok := netApp.FromContext(ctx); ok {
+		if s.serviceName == "" {
+			s.serviceName = info.Name()
+		}
+		if s.tracerProvider == nil {
+			s.tracerProvider = info.TracerProvider()
+		}
+		if s.propagator == nil {
+			s.propagator = info.Propagator()
+		}
+	}
+	if s.prometheus {
+		if err := s.initMetrics(ctx); err != nil {
+			return err
+		}
+	}
+	if _, err := s.endpointListen(); err != nil {
+		return err
+	}
+	logger.Infof("[HTTP] server listening on: %s", s.listener.Addr().String())
+	var err error
+	if s.tlsConf != nil {
+		// the certificates come from the tls config
+		err = s.httpServer.ServeTLS(s.listener, "", "")
+	} else {
+		err = s.httpServer.Serve(s.listener)
 	}
-	s.httpServer = srv
-	logger.Infof("[HTTP] server listening on: %s", s.address)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// Endpoint return a real address to registry endpoint, the server starts
+// listening when it is called before Start.
+func (s *Server) Endpoint() (*url.URL, error) {
+	return s.endpointListen()
+}
+
+// Ready returns a channel which is closed once the server is listening.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Stop waits for the active connections to become idle, once ctx is done
+// the server closes the remaining connections forcibly.
 func (s *Server) Stop(ctx context.Context) error {
 	logger.Info("[HTTP] server stopping")
-	return s.httpServer.Shutdown(ctx)
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil && ctx.Err() != nil {
+		logger.Errorf("[HTTP] server shutdown err=%v, force closing", err)
+		if e := s.httpServer.Close(); e != nil {
+			logger.Errorf("[HTTP] server close err=%v", e)
+		}
+	}
+	return err
+}
+
+// tracingMiddleware builds the otelgin middleware on the first request, so
+// that the service name, tracer provider and propagator can still come from
+// the app passed to Start.
+func (s *Server) tracingMiddleware() gin.HandlerFunc {
+	var (
+		once    sync.Once
+		handler gin.HandlerFunc
+	)
+	return func(c *gin.Context) {
+		once.Do(func() {
+			handler = otelgin.Middleware(s.serviceName,
+				otelgin.WithTracerProvider(s.tracerProvider),
+				otelgin.WithPropagators(s.propagator))
+		})
+		handler(c)
+	}
+}
+
+// endpointListen return a real address to registry endpoint
+func (s *Server) endpointListen() (*url.URL, error) {
+	s.once.Do(func() {
+		if s.listener == nil {
+			lis, err := net.Listen(s.network, s.address)
+			if err != nil {
+				s.err = err
+				return
+			}
+			s.listener = lis
+		} else {
+			// the listener given by WithListener decides the address
+			s.address = s.listener.Addr().String()
+		}
+		scheme := SchemeOfHttp
+		if s.tlsConf != nil {
+			scheme = SchemeOfHttps
+		}
+		endpoint, err := tool.Endpoint(scheme, s.address, s.listener)
+		if err != nil {
+			if e := s.listener.Close(); e != nil {
+				logger.Errorf("close %s listener catch err=%v", s.address, e)
+			}
+			s.err = err
+			return
+		}
+		s.endpoint = endpoint
+		close(s.ready)
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.endpoint, nil
 }
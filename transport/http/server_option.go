@@ -1,6 +1,17 @@
 package http
 
-import "github.com/gin-gonic/gin"
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/prometheus/client_golang/prometheus"
+	netGrpc "github.com/weiqiangxu/net/transport/grpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
 
 type ServerOption func(*Server)
 
@@ -16,20 +27,127 @@ func WithAddress(addr string) ServerOption {
 	}
 }
 
+// WithNetwork with server network, e.g. tcp, tcp4 or unix. The address of
+// a unix network is the socket path.
+func WithNetwork(network string) ServerOption {
+	return func(server *Server) {
+		server.network = network
+	}
+}
+
+// WithListener serve on lis instead of listening on network and address,
+// test harnesses can hand in a listener on an ephemeral port.
+func WithListener(lis net.Listener) ServerOption {
+	return func(server *Server) {
+		server.listener = lis
+	}
+}
+
+// WithTLSConfig serve HTTPS with the tls config, see tool.TLSConfig to load
+// the certificates from files or PEM bytes, pick them by SNI and reload
+// renewed ones without a restart.
+func WithTLSConfig(conf *tls.Config) ServerOption {
+	return func(server *Server) {
+		server.tlsConf = conf
+	}
+}
+
+// WithH2C serve cleartext HTTP/2 next to HTTP/1.1, both with prior
+// knowledge and with the Upgrade: h2c negotiation. It has no effect on
+// HTTPS servers which negotiate HTTP/2 with ALPN.
+func WithH2C(h2c bool) ServerOption {
+	return func(server *Server) {
+		server.h2c = h2c
+	}
+}
+
+// WithGateway mounts handler, usually a mux of NewGatewayMux, on the engine.
+// It serves the requests under prefix, or every request no route matched
+// when prefix is empty.
+func WithGateway(prefix string, handler http.Handler) ServerOption {
+	return func(server *Server) {
+		server.gatewayPrefix = prefix
+		server.gateway = handler
+	}
+}
+
+// WithGRPCWeb serve the gRPC-Web and gRPC-Web-Text requests with srv, so that
+// browsers can call its services including the server streaming ones. The
+// requests of other origins are denied unless they are allowed with
+// grpcweb.WithOriginFunc, their preflights allow every request header.
+func WithGRPCWeb(srv *netGrpc.Server, opts ...grpcweb.Option) ServerOption {
+	return func(server *Server) {
+		server.grpcWeb = srv
+		server.grpcWebOpts = opts
+	}
+}
+
 func WithPrometheus(enablePrometheus bool) ServerOption {
 	return func(server *Server) {
 		server.prometheus = enablePrometheus
 	}
 }
 
+// WithRegisterer register the request metrics of WithPrometheus on reg
+// instead of the registry of the app, or prometheus.DefaultRegisterer
+// outside of an app. /metrics serves reg when it is a prometheus.Gatherer
+// such as a *prometheus.Registry.
+func WithRegisterer(reg prometheus.Registerer) ServerOption {
+	return func(server *Server) {
+		server.registerer = reg
+	}
+}
+
+// WithDurationBuckets with the buckets in seconds of the request duration
+// histogram, default DefaultDurationBuckets. A server sharing the registerer
+// with a server started before it keeps the buckets of the first one.
+func WithDurationBuckets(buckets ...float64) ServerOption {
+	return func(server *Server) {
+		server.durationBuckets = buckets
+	}
+}
+
+// WithSizeBuckets with the buckets in bytes of the request and response size
+// histograms, default DefaultSizeBuckets. A server sharing the registerer with
+// a server started before it keeps the buckets of the first one.
+func WithSizeBuckets(buckets ...float64) ServerOption {
+	return func(server *Server) {
+		server.sizeBuckets = buckets
+	}
+}
+
 func WithProfile(profile bool) ServerOption {
 	return func(server *Server) {
 		server.profile = profile
 	}
 }
 
+// WithServiceName with the service name of the spans, it defaults to the
+// name of the app the server runs in.
+func WithServiceName(name string) ServerOption {
+	return func(server *Server) {
+		server.serviceName = name
+	}
+}
+
 func WithTracing(tracing bool) ServerOption {
 	return func(server *Server) {
 		server.tracing = tracing
 	}
 }
+
+// WithTracerProvider trace the requests with tp instead of the tracer
+// provider of the app, or the global one outside of an app.
+func WithTracerProvider(tp trace.TracerProvider) ServerOption {
+	return func(server *Server) {
+		server.tracerProvider = tp
+	}
+}
+
+// WithPropagator extract the trace context of the requests with p instead of
+// the propagator of the app, or the global one outside of an app.
+func WithPropagator(p propagation.TextMapPropagator) ServerOption {
+	return func(server *Server) {
+		server.propagator = p
+	}
+}
@@ -1,8 +1,22 @@
 package http
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/gin-gonic/gin"
+	netApp "github.com/weiqiangxu/net"
+	"github.com/weiqiangxu/net/tool"
+	"github.com/weiqiangxu/net/tool/tlstest"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semConv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"golang.org/x/net/http2"
 )
 
 func TestNewServer(t *testing.T) {
@@ -36,3 +50,296 @@ func TestNewServer(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_Endpoint(t *testing.T) {
+	srv := NewServer(WithAddress("127.0.0.1:0"))
+	endpoint, err := srv.Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Scheme != SchemeOfHttp || endpoint.Port() == "0" {
+		t.Fatalf("Endpoint() = %s, want a http endpoint with the bound port", endpoint)
+	}
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	resp, err := http.Get(endpoint.String() + "/healthC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /healthC = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_Listen(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "http.sock")
+	tests := []struct {
+		name   string
+		opts   []ServerOption
+		scheme string
+		target string
+		dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+	}{
+		{
+			name:   "listener",
+			opts:   []ServerOption{WithListener(lis)},
+			scheme: SchemeOfHttp,
+			target: "http://" + lis.Addr().String(),
+		},
+		{
+			name:   "unix socket",
+			opts:   []ServerOption{WithNetwork("unix"), WithAddress(sock)},
+			scheme: tool.SchemeOfUnix,
+			target: "http://unix",
+			dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(tt.opts...)
+			endpoint, err := srv.Endpoint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if endpoint.Scheme != tt.scheme {
+				t.Fatalf("Endpoint() = %s, want scheme %s", endpoint, tt.scheme)
+			}
+			go func() {
+				_ = srv.Start(context.Background())
+			}()
+			<-srv.Ready()
+			client := &http.Client{Transport: &http.Transport{DialContext: tt.dial}}
+			resp, err := client.Get(tt.target + "/healthC")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("GET /healthC = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if err := srv.Stop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestServer_ServiceName(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ServerOption
+		want string
+	}{
+		{
+			name: "from app context",
+			opts: []ServerOption{WithTracing(true)},
+			want: "app",
+		},
+		{
+			name: "explicit option",
+			opts: []ServerOption{WithTracing(true), WithServiceName("user")},
+			want: "user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(append(tt.opts, WithAddress("127.0.0.1:0"))...)
+			ctx := netApp.NewContext(context.Background(), netApp.New(netApp.Name("app")))
+			go func() {
+				_ = srv.Start(ctx)
+			}()
+			<-srv.Ready()
+			endpoint, _ := srv.Endpoint()
+			resp, err := http.Get(endpoint.String() + "/healthC")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if srv.serviceName != tt.want {
+				t.Errorf("serviceName = %s, want %s", srv.serviceName, tt.want)
+			}
+			if err := srv.Stop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestServer_AppTracerProvider(t *testing.T) {
+	// every app traces with its own provider, the apps running in parallel
+	// do not see the spans of each other
+	for _, name := range []string{"foo", "bar"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			exp := tracetest.NewInMemoryExporter()
+			srv := NewServer(WithAddress("127.0.0.1:0"), WithTracing(true))
+			srv.Server().GET("/ping", func(c *gin.Context) {
+				c.String(http.StatusOK, "pong")
+			})
+			var app *netApp.App
+			call := netApp.AfterStart(func(ctx context.Context) error {
+				defer app.Stop()
+				endpoint, _ := srv.Endpoint()
+				resp, err := http.Get(endpoint.String() + "/ping")
+				if err != nil {
+					return err
+				}
+				return resp.Body.Close()
+			})
+			app = netApp.New(netApp.Name(name), netApp.Server(srv), netApp.ExportMemory(exp), call)
+			if err := app.Run(); err != nil {
+				t.Fatal(err)
+			}
+			spans := exp.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("recorded spans = %d, want 1", len(spans))
+			}
+			got, _ := spans[0].Resource.Set().Value(semConv.ServiceNameKey)
+			if got.AsString() != name {
+				t.Errorf("span service name = %q, want %q", got.AsString(), name)
+			}
+		})
+	}
+}
+
+func TestServer_TLS(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	defaultCert, defaultKey := ca.Issue(t, "127.0.0.1")
+	apiCert, apiKey := ca.Issue(t, "api.local")
+	conf, err := tool.TLSConfig{
+		CertPEM: defaultCert,
+		KeyPEM:  defaultKey,
+		SNI:     []tool.TLSKeyPair{{CertPEM: apiCert, KeyPEM: apiKey}},
+	}.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(WithAddress("127.0.0.1:0"), WithTLSConfig(conf))
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+	if endpoint.Scheme != SchemeOfHttps {
+		t.Fatalf("Endpoint() = %s, want scheme %s", endpoint, SchemeOfHttps)
+	}
+	tests := []struct {
+		name       string
+		serverName string
+		maxVersion uint16
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "default certificate",
+			want: "127.0.0.1",
+		},
+		{
+			name:       "certificate picked by sni",
+			serverName: "api.local",
+			want:       "api.local",
+		},
+		{
+			name:       "tls version below minimum",
+			maxVersion: tls.VersionTLS11,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConf, err := tool.TLSConfig{CAPEM: ca.CertPEM, ServerName: tt.serverName}.Client()
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientConf.MinVersion, clientConf.MaxVersion = tt.maxVersion, tt.maxVersion
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConf}}
+			resp, err := client.Get(endpoint.String() + "/healthC")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GET /healthC error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			_ = resp.Body.Close()
+			if got := resp.TLS.PeerCertificates[0].Subject.CommonName; got != tt.want {
+				t.Errorf("certificate = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_H2C(t *testing.T) {
+	srv := NewServer(WithAddress("127.0.0.1:0"), WithH2C(true))
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	defer func() {
+		_ = srv.Stop(context.Background())
+	}()
+	endpoint, _ := srv.Endpoint()
+	t.Run("prior knowledge", func(t *testing.T) {
+		client := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}}
+		resp, err := client.Get(endpoint.String() + "/healthC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /healthC = %s %d, want HTTP/2.0 %d", resp.Proto, resp.StatusCode, http.StatusOK)
+		}
+	})
+	t.Run("upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", endpoint.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		req, _ := http.NewRequest(http.MethodGet, endpoint.String()+"/healthC", nil)
+		req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+		req.Header.Set("Upgrade", "h2c")
+		// an empty SETTINGS payload
+		req.Header.Set("HTTP2-Settings", "")
+		if err := req.Write(conn); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Errorf("upgrade status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+		}
+	})
+	t.Run("http/1.1", func(t *testing.T) {
+		resp, err := http.Get(endpoint.String() + "/healthC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.ProtoMajor != 1 || resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /healthC = %s %d, want HTTP/1.1 %d", resp.Proto, resp.StatusCode, http.StatusOK)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// connListener is a net.Listener fed with the connections routed to one of
+// the servers sharing the real listener.
+type connListener struct {
+	addr  func() net.Addr
+	conns chan net.Conn
+	once  sync.Once
+	done  chan struct{}
+}
+
+func newConnListener(addr func() net.Addr) *connListener {
+	return &connListener{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close is called by the server which stops, it may be called more than once.
+func (l *connListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr()
+}
+
+// serve hands c over to the server, c is closed when the server stopped accepting.
+func (l *connListener) serve(c net.Conn) {
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		_ = c.Close()
+	}
+}
+
+// sniffConn replays the bytes read while sniffing before reading the connection.
+type sniffConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *sniffConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// settingsAckFilter drops the first SETTINGS ACK frame read from r, it
+// acknowledges the SETTINGS of the sniffer and the HTTP/2 server of net/http
+// rejects the acknowledgement of SETTINGS it never sent.
+type settingsAckFilter struct {
+	r       io.Reader
+	pending []byte
+	dropped bool
+}
+
+func (f *settingsAckFilter) Read(b []byte) (int, error) {
+	for !f.dropped && len(f.pending) == 0 {
+		frame := make([]byte, frameHeaderLen)
+		if _, err := io.ReadFull(f.r, frame); err != nil {
+			return 0, err
+		}
+		length := int(frame[0])<<16 | int(frame[1])<<8 | int(frame[2])
+		frame = append(frame, make([]byte, length)...)
+		if _, err := io.ReadFull(f.r, frame[frameHeaderLen:]); err != nil {
+			return 0, err
+		}
+		if http2.FrameType(frame[3]) == http2.FrameSettings && http2.Flags(frame[4]).Has(http2.FlagSettingsAck) {
+			f.dropped = true
+			continue
+		}
+		f.pending = frame
+	}
+	if len(f.pending) > 0 {
+		n := copy(b, f.pending)
+		f.pending = f.pending[n:]
+		return n, nil
+	}
+	return f.r.Read(b)
+}
+
+// frameHeaderLen is the length of an HTTP/2 frame header.
+const frameHeaderLen = 9
@@ -0,0 +1,273 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weiqiangxu/common-config/logger"
+	"github.com/weiqiangxu/net/tool"
+	"github.com/weiqiangxu/net/transport"
+	netGrpc "github.com/weiqiangxu/net/transport/grpc"
+	netHttp "github.com/weiqiangxu/net/transport/http"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	_ transport.Server     = (*Server)(nil)
+	_ transport.Readier    = (*Server)(nil)
+	_ transport.Endpointer = (*Server)(nil)
+)
+
+const (
+	DefaultNetProtocol  = "tcp"
+	DefaultNetAddress   = ":0"
+	DefaultSniffTimeout = 10 * time.Second
+	// maxSniffFrames bounds the frames read before the first HEADERS frame.
+	maxSniffFrames = 16
+)
+
+// Server serves a gRPC server and an HTTP server on a single listener. The
+// HTTP/2 connections whose first request has the content-type
+// application/grpc go to the gRPC server, everything else to the HTTP
+// server, which needs WithH2C to serve the other cleartext HTTP/2 requests.
+// TLS is not terminated by the Server, put it behind a proxy which does.
+type Server struct {
+	grpc         *netGrpc.Server
+	http         *netHttp.Server
+	grpcListener *connListener
+	httpListener *connListener
+	listener     net.Listener
+	once         sync.Once
+	err          error
+	network      string
+	address      string
+	endpoint     *url.URL
+	sniffTimeout time.Duration
+	ready        chan struct{}
+	closing      chan struct{}
+	closeOnce    sync.Once
+}
+
+// NewServer create a server sharing its listener between grpcSrv and
+// httpSrv. They must not be started on their own.
+func NewServer(grpcSrv *netGrpc.Server, httpSrv *netHttp.Server, opts ...ServerOption) *Server {
+	srv := &Server{
+		grpc:         grpcSrv,
+		http:         httpSrv,
+		network:      DefaultNetProtocol,
+		address:      DefaultNetAddress,
+		sniffTimeout: DefaultSniffTimeout,
+		ready:        make(chan struct{}),
+		closing:      make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(srv)
+	}
+	srv.grpcListener = newConnListener(srv.addr)
+	srv.httpListener = newConnListener(srv.addr)
+	netGrpc.Listener(srv.grpcListener)(grpcSrv)
+	netHttp.WithListener(srv.httpListener)(httpSrv)
+	return srv
+}
+
+// Start listens and runs both servers until Stop.
+func (s *Server) Start(ctx context.Context) error {
+	if _, err := s.endpointListen(); err != nil {
+		return err
+	}
+	logger.Infof("[mux] server listening on: %s", s.listener.Addr().String())
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		return s.grpc.Start(ctx)
+	})
+	eg.Go(func() error {
+		return s.http.Start(ctx)
+	})
+	eg.Go(s.serve)
+	// a failed server takes the other one down, so that Start returns its error
+	go func() {
+		<-egCtx.Done()
+		select {
+		case <-s.closing:
+			return
+		default:
+		}
+		if err := s.Stop(context.Background()); err != nil {
+			logger.Errorf("[mux] stop after a failed server catch err=%v", err)
+		}
+	}()
+	return eg.Wait()
+}
+
+// Stop closes the listener and stops both servers gracefully with ctx.
+func (s *Server) Stop(ctx context.Context) error {
+	logger.Info("[mux] server stopping")
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	if _, err := s.endpointListen(); err == nil {
+		if err := s.listener.Close(); err != nil {
+			logger.Errorf("[mux] close listener catch err=%v", err)
+		}
+	}
+	eg := errgroup.Group{}
+	eg.Go(func() error {
+		return s.grpc.Stop(ctx)
+	})
+	eg.Go(func() error {
+		return s.http.Stop(ctx)
+	})
+	err := eg.Wait()
+	// a server which failed to start never closes its listener, the routed
+	// connections are closed instead of waiting for it
+	_ = s.grpcListener.Close()
+	_ = s.httpListener.Close()
+	return err
+}
+
+// Endpoint return a real address to registry endpoint, it has the grpc
+// scheme and the HTTP server is reachable on the same host.
+func (s *Server) Endpoint() (*url.URL, error) {
+	return s.endpointListen()
+}
+
+// Ready returns a channel which is closed once the server is listening.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// serve accepts the connections and routes them in the background.
+func (s *Server) serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.closing:
+				return nil
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			// nothing reaches the servers anymore, let them return
+			_ = s.grpcListener.Close()
+			_ = s.httpListener.Close()
+			return err
+		}
+		go s.route(conn)
+	}
+}
+
+// route sniffs conn and hands it over to the server of its protocol.
+func (s *Server) route(conn net.Conn) {
+	var buf bytes.Buffer
+	if s.sniffTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(s.sniffTimeout))
+	}
+	proto, err := sniff(io.TeeReader(conn, &buf), conn)
+	if err != nil {
+		logger.Errorf("[mux] sniff %s catch err=%v", conn.RemoteAddr(), err)
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	switch proto {
+	case protoGrpc:
+		// gRPC ignores the acknowledgement of the sniffer SETTINGS
+		s.grpcListener.serve(&sniffConn{Conn: conn, r: io.MultiReader(&buf, conn)})
+	case protoHTTP2:
+		preface := buf.Next(len(http2.ClientPreface))
+		frames := &settingsAckFilter{r: io.MultiReader(&buf, conn)}
+		s.httpListener.serve(&sniffConn{Conn: conn, r: io.MultiReader(bytes.NewReader(preface), frames)})
+	default:
+		s.httpListener.serve(&sniffConn{Conn: conn, r: io.MultiReader(&buf, conn)})
+	}
+}
+
+type protocol int
+
+const (
+	protoHTTP1 protocol = iota
+	protoHTTP2
+	protoGrpc
+)
+
+// sniff reads the start of a connection from r and tells its protocol. An
+// HTTP/2 client gets an empty SETTINGS frame on w, since gRPC clients wait
+// for it before they send the first request.
+func sniff(r io.Reader, w io.Writer) (protocol, error) {
+	preface := []byte(http2.ClientPreface)
+	buf := make([]byte, len(preface))
+	for n := 0; n < len(buf); {
+		m, err := r.Read(buf[n:])
+		n += m
+		if !bytes.Equal(buf[:n], preface[:n]) {
+			return protoHTTP1, nil
+		}
+		if err != nil {
+			return protoHTTP1, err
+		}
+	}
+	framer := http2.NewFramer(w, r)
+	if err := framer.WriteSettings(); err != nil {
+		return protoHTTP2, err
+	}
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	for i := 0; i < maxSniffFrames; i++ {
+		f, err := framer.ReadFrame()
+		if err != nil {
+			return protoHTTP2, err
+		}
+		if h, ok := f.(*http2.MetaHeadersFrame); ok {
+			for _, field := range h.Fields {
+				if field.Name == "content-type" && strings.HasPrefix(field.Value, "application/grpc") {
+					return protoGrpc, nil
+				}
+			}
+			return protoHTTP2, nil
+		}
+	}
+	return protoHTTP2, nil
+}
+
+// addr returns the address of the real listener to the servers.
+func (s *Server) addr() net.Addr {
+	if _, err := s.endpointListen(); err != nil {
+		return &net.TCPAddr{}
+	}
+	return s.listener.Addr()
+}
+
+// endpointListen return a real address to registry endpoint
+func (s *Server) endpointListen() (*url.URL, error) {
+	s.once.Do(func() {
+		lis, err := net.Listen(s.network, s.address)
+		if err != nil {
+			s.err = err
+			return
+		}
+		endpoint, err := tool.Endpoint(netGrpc.SchemeOfGrpc, s.address, lis)
+		if err != nil {
+			if e := lis.Close(); e != nil {
+				logger.Errorf("close %s listener catch err=%v", s.address, e)
+			}
+			s.err = err
+			return
+		}
+		s.listener = lis
+		s.endpoint = endpoint
+		close(s.ready)
+	})
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.endpoint, nil
+}
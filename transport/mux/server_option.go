@@ -0,0 +1,27 @@
+package mux
+
+import "time"
+
+type ServerOption func(o *Server)
+
+// Network with server network, e.g. tcp or unix.
+func Network(network string) ServerOption {
+	return func(s *Server) {
+		s.network = network
+	}
+}
+
+// Address with server address.
+func Address(addr string) ServerOption {
+	return func(s *Server) {
+		s.address = addr
+	}
+}
+
+// SniffTimeout with the time a connection gets to send its first request
+// before it is closed. Zero disables it.
+func SniffTimeout(timeout time.Duration) ServerOption {
+	return func(s *Server) {
+		s.sniffTimeout = timeout
+	}
+}
@@ -0,0 +1,122 @@
+package mux
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	netGrpc "github.com/weiqiangxu/net/transport/grpc"
+	netHttp "github.com/weiqiangxu/net/transport/http"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer(
+		netGrpc.NewServer(),
+		netHttp.NewServer(netHttp.WithH2C(true)),
+		Address("127.0.0.1:0"),
+	)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Start(context.Background())
+	}()
+	<-srv.Ready()
+	endpoint, err := srv.Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	tests := []struct {
+		name   string
+		client *http.Client
+		proto  int
+	}{
+		{
+			name:   "http/1.1",
+			client: http.DefaultClient,
+			proto:  1,
+		},
+		{
+			name:   "h2c",
+			client: h2c,
+			proto:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get("http://" + endpoint.Host + "/healthC")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.ProtoMajor != tt.proto {
+				t.Errorf("GET /healthC = %s %d, want HTTP/%d %d", resp.Proto, resp.StatusCode, tt.proto, http.StatusOK)
+			}
+		})
+	}
+	t.Run("grpc", func(t *testing.T) {
+		conn, err := netGrpc.Dial(context.Background(), netGrpc.WithEndpoint(endpoint.Host), netGrpc.WithInSecure(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Errorf("Check() = %s, want %s", resp.Status, grpc_health_v1.HealthCheckResponse_SERVING)
+		}
+	})
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Start() did not return after Stop()")
+	}
+}
+
+func TestServer_Failure(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	// the gRPC server fails to register its metrics
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "grpc_server_started_total"}))
+	srv := NewServer(
+		netGrpc.NewServer(netGrpc.Prometheus(true), netGrpc.Registerer(reg)),
+		netHttp.NewServer(),
+		Address("127.0.0.1:0"),
+	)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Start(context.Background())
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("Start() error = nil, want the error of the gRPC server")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start() did not return after the gRPC server failed")
+	}
+	endpoint, _ := srv.Endpoint()
+	if conn, err := net.DialTimeout("tcp", endpoint.Host, time.Second); err == nil {
+		_ = conn.Close()
+		t.Error("the listener is still open")
+	}
+}
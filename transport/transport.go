@@ -1,8 +1,24 @@
 package transport
 
-import "context"
+import (
+	"context"
+	"net/url"
+)
 
 type Server interface {
 	Start(context.Context) error
 	Stop(context.Context) error
 }
+
+// Readier is implemented by servers which can tell when they are listening,
+// the App waits for it before starting the next server.
+type Readier interface {
+	// Ready returns a channel which is closed once the server accepts connections.
+	Ready() <-chan struct{}
+}
+
+// Endpointer is implemented by servers which know the address they bound,
+// e.g. grpc://10.0.0.1:9000 when listening on :0.
+type Endpointer interface {
+	Endpoint() (*url.URL, error)
+}